@@ -1744,8 +1744,7 @@ func render() {
 	backupMap.Copy(hm)
 	mapmin = 1e8
 	mapmax = -1e8
-	for i := 0; i < HM_WIDTH*HM_HEIGHT; i++ {
-		v := hm.GetNthValue(i)
+	for _, v := range hm.Values() {
 		if v < 0.0 || v > 1.0 {
 			isNormalized = false
 		}
@@ -1761,10 +1760,11 @@ func render() {
 		backupMap.Normalize()
 	}
 	// render the TCODHeightMap
+	values := hm.Values()
 	for x := 0; x < HM_WIDTH; x++ {
 		for y := 0; y < HM_HEIGHT; y++ {
 			//z := backupMap.GetValue(x, y)
-			z := hm.GetValue(x, y)
+			z := values[y*HM_WIDTH+x]
 			val := uint8(z * 255)
 			if slope {
 				// render the slope map
@@ -1816,11 +1816,7 @@ func message(delay float32, fmts string, v ...interface{}) {
 
 func backup() {
 	// save the heightmap & RNG states
-	for x := 0; x < HM_WIDTH; x++ {
-		for y := 0; y < HM_HEIGHT; y++ {
-			hmold.SetValue(x, y, hm.GetValue(x, y))
-		}
-	}
+	hmold.SetValues(hm.Values())
 	fmt.Printf("Saving to backupRNG!\n")
 	backupRnd = rnd.Save()
 	oldNormalized = isNormalized
@@ -1830,11 +1826,7 @@ func backup() {
 
 func restore() {
 	// restore the previously saved heightmap & RNG states
-	for x := 0; x < HM_WIDTH; x++ {
-		for y := 0; y < HM_HEIGHT; y++ {
-			hm.SetValue(x, y, hmold.GetValue(x, y))
-		}
-	}
+	hm.SetValues(hmold.Values())
 	fmt.Printf("Restoring from backupRnd \n")
 	if backupRnd != nil {
 		rnd.Restore(backupRnd)
@@ -1955,9 +1947,10 @@ func exportCppCbk(w tcod.IWidget, data interface{}) {
 
 func exportBmpCbk(w tcod.IWidget, data interface{}) {
 	img := tcod.NewImage(HM_WIDTH, HM_HEIGHT)
+	values := hm.Values()
 	for x := 0; x < HM_WIDTH; x++ {
 		for y := 0; y < HM_HEIGHT; y++ {
-			z := hm.GetValue(x, y)
+			z := values[y*HM_WIDTH+x]
 			val := (uint8)(z * 255)
 			if slope {
 				// render the slope map
@@ -0,0 +1,144 @@
+package tcod
+
+import (
+	"bytes"
+	"fmt"
+	"unicode"
+)
+
+//
+// .cfg scanning
+//
+
+// cfgScanner splits data in libtcod's .cfg syntax into tokens, for the files
+// read in Go rather than with Parser, which can't report errors to its caller.
+type cfgScanner struct {
+	source string
+	data   []byte
+	pos    int
+	line   int
+	quoted bool // the last token was a string
+}
+
+func newCfgScanner(source string, data []byte) *cfgScanner {
+	return &cfgScanner{source: source, data: data, line: 1}
+}
+
+func (p *cfgScanner) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("tcod: %s:%d: %s", p.source, p.line, fmt.Sprintf(format, args...))
+}
+
+func (p *cfgScanner) expect(symbol string) error {
+	token, err := p.next()
+	if err != nil {
+		return err
+	}
+	if token != symbol || p.quoted {
+		return p.errorf("expected %q, found %q", symbol, token)
+	}
+	return nil
+}
+
+func (p *cfgScanner) expectString() (string, error) {
+	token, err := p.next()
+	if err != nil {
+		return "", err
+	}
+	if !p.quoted {
+		return "", p.errorf("expected a quoted string, found %q", token)
+	}
+	return token, nil
+}
+
+// next returns the next token: a quoted string, with its escapes replaced, a
+// word, or one of the symbols { } [ ] = and a comma.  It returns an empty,
+// unquoted token at the end of the data.
+func (p *cfgScanner) next() (string, error) {
+	if err := p.skipSpace(); err != nil {
+		return "", err
+	}
+	p.quoted = false
+	if p.pos == len(p.data) {
+		return "", nil
+	}
+
+	switch c := p.data[p.pos]; {
+	case c == '"':
+		return p.quotedString()
+	case c == '{' || c == '}' || c == '[' || c == ']' || c == '=' || c == ',':
+		p.pos++
+		return string(c), nil
+	case isCfgIdentifier(c):
+		start := p.pos
+		for p.pos < len(p.data) && isCfgIdentifier(p.data[p.pos]) {
+			p.pos++
+		}
+		return string(p.data[start:p.pos]), nil
+	default:
+		return "", p.errorf("unexpected character %q", c)
+	}
+}
+
+func isCfgIdentifier(c byte) bool {
+	return c == '_' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+// skipSpace skips whitespace and // or /* */ comments.
+func (p *cfgScanner) skipSpace() error {
+	for p.pos < len(p.data) {
+		rest := p.data[p.pos:]
+		switch {
+		case rest[0] == '\n':
+			p.line++
+			p.pos++
+		case unicode.IsSpace(rune(rest[0])):
+			p.pos++
+		case bytes.HasPrefix(rest, []byte("//")):
+			end := bytes.IndexByte(rest, '\n')
+			if end < 0 {
+				end = len(rest)
+			}
+			p.pos += end
+		case bytes.HasPrefix(rest, []byte("/*")):
+			end := bytes.Index(rest, []byte("*/"))
+			if end < 0 {
+				return p.errorf("unterminated comment")
+			}
+			p.line += bytes.Count(rest[:end], []byte("\n"))
+			p.pos += end + 2
+		default:
+			return nil
+		}
+	}
+	return nil
+}
+
+// quotedString reads a string in double quotes, replacing the usual backslash
+// escapes.
+func (p *cfgScanner) quotedString() (string, error) {
+	var s []byte
+	for p.pos++; p.pos < len(p.data); p.pos++ {
+		c := p.data[p.pos]
+		switch c {
+		case '"':
+			p.pos++
+			p.quoted = true
+			return string(s), nil
+		case '\n':
+			return "", p.errorf("unterminated string")
+		case '\\':
+			p.pos++
+			if p.pos == len(p.data) {
+				return "", p.errorf("unterminated string")
+			}
+			switch c = p.data[p.pos]; c {
+			case 'n':
+				c = '\n'
+			case 't':
+				c = '\t'
+			}
+		}
+		s = append(s, c)
+	}
+	return "", p.errorf("unterminated string")
+}
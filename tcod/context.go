@@ -0,0 +1,200 @@
+package tcod
+
+/*
+ #include <stdlib.h>
+ #include "include/libtcod.h"
+*/
+import "C"
+
+import (
+	"errors"
+	"runtime"
+	"unsafe"
+)
+
+//
+// Context
+//
+
+// ContextOptions configures the window and renderer created by NewContext.
+type ContextOptions struct {
+	// Title is the title of the window.
+	Title string
+
+	// Columns and Rows are the desired size of the window in tiles.  They're
+	// ignored if PixelWidth and PixelHeight are set.
+	Columns, Rows int
+
+	// PixelWidth and PixelHeight are the desired size of the window in pixels.
+	// If zero, the size is derived from Columns, Rows and the Tileset.
+	PixelWidth, PixelHeight int
+
+	// WindowX and WindowY are the starting position of the window.  Zero
+	// values let SDL choose the position.
+	WindowX, WindowY int
+
+	// Renderer is the renderer to use, such as SDL2 or OpenGL2.
+	Renderer Renderer
+
+	// Tileset is the tileset to render with.  If nil, libtcod uses a platform
+	// specific fallback font.
+	Tileset *Tileset
+
+	// VSync enables vertical sync whenever possible.
+	VSync bool
+
+	// SDLWindowFlags is a bitmask of SDL window flags, such as
+	// WindowResizable or WindowFullscreenDesktop.
+	SDLWindowFlags int
+}
+
+// ViewportOptions control how a console is scaled to fit the window when it's
+// presented.
+type ViewportOptions struct {
+	// KeepAspect letter-boxes the console rather than stretching it.
+	KeepAspect bool
+
+	// IntegerScaling scales the console in whole increments only.
+	IntegerScaling bool
+
+	// ClearColor fills the letter-boxed area around the console.
+	ClearColor Color
+
+	// AlignX and AlignY position a letter-boxed console, from 0.0 (top-left)
+	// to 1.0 (bottom-right).
+	AlignX, AlignY float32
+}
+
+// Context is a rendering context: a window and renderer that any Console may be
+// presented to.  Unlike RootConsole, a context doesn't rely on libtcod's global
+// root console.
+type Context struct {
+	Data    *C.TCOD_Context
+	tileset *Tileset // keeps the tileset from being finalized while in use
+}
+
+func deleteContext(context *Context) {
+	if context.Data != nil {
+		C.TCOD_context_delete(context.Data)
+		context.Data = nil
+	}
+}
+
+// NewContext opens a new window and renderer configured by options.
+func NewContext(options ContextOptions) (*Context, error) {
+	ctitle := C.CString(options.Title)
+	defer C.free(unsafe.Pointer(ctitle))
+
+	var params C.TCOD_ContextParams
+	params.tcod_version = C.TCOD_COMPILEDVERSION
+	params.window_x = C.int(options.WindowX)
+	params.window_y = C.int(options.WindowY)
+	params.pixel_width = C.int(options.PixelWidth)
+	params.pixel_height = C.int(options.PixelHeight)
+	params.columns = C.int(options.Columns)
+	params.rows = C.int(options.Rows)
+	params.renderer_type = C.int(options.Renderer)
+	if options.Tileset != nil {
+		params.tileset = options.Tileset.Data
+	}
+	if options.VSync {
+		params.vsync = 1
+	}
+	params.sdl_window_flags = C.int(options.SDLWindowFlags)
+	params.window_title = ctitle
+
+	var data *C.TCOD_Context
+	if err := toError(C.TCOD_context_new(&params, &data)); err != nil {
+		return nil, err
+	}
+
+	result := &Context{Data: data, tileset: options.Tileset}
+	runtime.SetFinalizer(result, deleteContext)
+	return result, nil
+}
+
+// Close destroys the context's window and renderer.  The context may not be used
+// afterwards.
+func (context *Context) Close() {
+	deleteContext(context)
+}
+
+// Present renders console to the window, stretching it to fill the screen.  The
+// console may be any size, but must be an offscreen console created by
+// NewConsole.
+func (context *Context) Present(console *Console) error {
+	if console.Data == nil {
+		return errors.New("tcod: the root console can't be presented to a context")
+	}
+	return toError(C.TCOD_context_present(context.Data, console.Data, nil))
+}
+
+// PresentWithViewport renders console to the window, scaled according to
+// viewport.
+func (context *Context) PresentWithViewport(console *Console, viewport ViewportOptions) error {
+	if console.Data == nil {
+		return errors.New("tcod: the root console can't be presented to a context")
+	}
+
+	cviewport := C.TCOD_viewport_new()
+	defer C.TCOD_viewport_delete(cviewport)
+
+	cviewport.keep_aspect = fromBool(viewport.KeepAspect)
+	cviewport.integer_scaling = fromBool(viewport.IntegerScaling)
+	cviewport.clear_color.r = C.uint8_t(viewport.ClearColor.R)
+	cviewport.clear_color.g = C.uint8_t(viewport.ClearColor.G)
+	cviewport.clear_color.b = C.uint8_t(viewport.ClearColor.B)
+	cviewport.clear_color.a = 255
+	cviewport.align_x = C.float(viewport.AlignX)
+	cviewport.align_y = C.float(viewport.AlignY)
+
+	return toError(C.TCOD_context_present(context.Data, console.Data, cviewport))
+}
+
+// ScreenPixelToTile converts window pixel coordinates to tile coordinates, based
+// on the console and viewport last presented.
+func (context *Context) ScreenPixelToTile(x, y float64) (tx, ty float64, err error) {
+	cx, cy := C.double(x), C.double(y)
+	err = toError(C.TCOD_context_screen_pixel_to_tile_d(context.Data, &cx, &cy))
+	tx, ty = float64(cx), float64(cy)
+	return
+}
+
+// ScreenPixelToTileInt is the same as ScreenPixelToTile, but works in whole
+// pixels and tiles.
+func (context *Context) ScreenPixelToTileInt(x, y int) (tx, ty int, err error) {
+	cx, cy := C.int(x), C.int(y)
+	err = toError(C.TCOD_context_screen_pixel_to_tile_i(context.Data, &cx, &cy))
+	tx, ty = int(cx), int(cy)
+	return
+}
+
+// SaveScreenshot saves the last presented console to a PNG file.
+func (context *Context) SaveScreenshot(filename string) error {
+	cfilename := C.CString(filename)
+	defer C.free(unsafe.Pointer(cfilename))
+	return toError(C.TCOD_context_save_screenshot(context.Data, cfilename))
+}
+
+// ChangeTileset switches the tileset the context renders with.
+func (context *Context) ChangeTileset(tileset *Tileset) error {
+	if err := toError(C.TCOD_context_change_tileset(context.Data, tileset.Data)); err != nil {
+		return err
+	}
+	context.tileset = tileset
+	return nil
+}
+
+// GetRenderer returns the renderer the context is using.
+func (context *Context) GetRenderer() Renderer {
+	return Renderer(C.TCOD_context_get_renderer_type(context.Data))
+}
+
+// RecommendedConsoleSize returns the console size that fills the window with
+// tiles scaled by magnification.  A magnification of 0 is treated as 1.
+func (context *Context) RecommendedConsoleSize(magnification float32) (columns, rows int, err error) {
+	var ccolumns, crows C.int
+	err = toError(C.TCOD_context_recommended_console_size(context.Data, C.float(magnification), &ccolumns, &crows))
+	columns, rows = int(ccolumns), int(crows)
+	return
+}
@@ -0,0 +1,743 @@
+// Package dice parses and rolls dice expressions, and works out their exact
+// probability distributions for balancing.
+//
+// Expressions accept libtcod's dice syntax, such as "0.5x3d5+2", as a subset of:
+//
+//	expr     = term { ("+" | "-") term }
+//	term     = (libtcod | factor) { ("*" | "x" | "/") factor }
+//	libtcod  = number ("x" | "*") dice [("+" | "-") number]
+//	factor   = "-" factor | "(" expr ")" | dice | number
+//	dice     = [count] "d" (sides | "%") { modifier }
+//	modifier = "!"                  explode: roll again and add on the highest face
+//	         | "r" n                reroll dice showing n or less
+//	         | ("k" | "kh") n       keep the n highest dice
+//	         | "kl" n               keep the n lowest dice
+//	         | "dh" n               drop the n highest dice
+//	         | "dl" n               drop the n lowest dice
+//
+// so "4d6kh3", "d6!", "2d20kl1+5" and "(2d6+1d4)*2" are all valid.  Each
+// modifier may be used once per group, counting the keep and drop modifiers as
+// one.
+//
+// The libtcod form is evaluated as libtcod does, adding before multiplying and
+// truncating the result, so "0.5x3d5+2" is (3d5+2)*0.5, from 2 to 8, rather than
+// (3d5*0.5)+2.  Other results are truncated to integers at the end, and dividing
+// by zero gives zero.  A die explodes at most MaxExplosions times in a row.
+package dice
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// MaxExplosions is the most times a single exploding die is rolled again.
+const MaxExplosions = 10
+
+// Limits on dice groups, to keep distributions quick to work out.  Besides its
+// count and sides, a group can have at most maxTotals totals, and keeping or
+// dropping dice can take at most maxKeepSteps steps, as counted by keepSteps.
+const (
+	maxCount     = 100
+	maxSides     = 1000
+	maxTotals    = 1 << 17
+	maxKeepSteps = 1 << 28
+)
+
+// Roller is a source of random integers from min to max inclusive, such as a
+// *tcod.Random.
+type Roller interface {
+	GetInt(min, max int) int
+}
+
+// Expression is a parsed dice expression.
+type Expression struct {
+	src  string
+	root node
+}
+
+// Parse parses a dice expression.
+func Parse(s string) (*Expression, error) {
+	p := &parser{src: s}
+	root, err := p.expr()
+	if err != nil {
+		return nil, err
+	}
+	p.skipSpace()
+	if p.pos < len(p.src) {
+		return nil, p.errorf("unexpected %q", p.src[p.pos])
+	}
+	return &Expression{src: s, root: root}, nil
+}
+
+// MustParse is like Parse, but panics if the expression is invalid.  It's meant
+// for expressions written into the game.
+func MustParse(s string) *Expression {
+	e, err := Parse(s)
+	if err != nil {
+		panic(err)
+	}
+	return e
+}
+
+// Roll parses and rolls an expression once.
+func Roll(r Roller, s string) (int, error) {
+	e, err := Parse(s)
+	if err != nil {
+		return 0, err
+	}
+	return e.Roll(r), nil
+}
+
+func (e *Expression) String() string {
+	return e.src
+}
+
+// Roll rolls the expression once.
+func (e *Expression) Roll(r Roller) int {
+	return int(e.root.roll(r))
+}
+
+// Distribution works out the probability of every possible result.
+func (e *Expression) Distribution() Distribution {
+	result := make(Distribution)
+	for v, p := range e.root.dist() {
+		result[int(v)] += p
+	}
+	return result
+}
+
+// Min returns the lowest possible result.
+func (e *Expression) Min() int {
+	return e.Distribution().Min()
+}
+
+// Max returns the highest possible result.
+func (e *Expression) Max() int {
+	return e.Distribution().Max()
+}
+
+// Average returns the expected result.
+func (e *Expression) Average() float64 {
+	return e.Distribution().Mean()
+}
+
+// Distribution maps each possible result to its probability.
+type Distribution map[int]float64
+
+// Results returns the possible results in increasing order.
+func (d Distribution) Results() []int {
+	results := make([]int, 0, len(d))
+	for v := range d {
+		results = append(results, v)
+	}
+	sort.Ints(results)
+	return results
+}
+
+func (d Distribution) Min() int {
+	results := d.Results()
+	if len(results) == 0 {
+		return 0
+	}
+	return results[0]
+}
+
+func (d Distribution) Max() int {
+	results := d.Results()
+	if len(results) == 0 {
+		return 0
+	}
+	return results[len(results)-1]
+}
+
+func (d Distribution) Mean() float64 {
+	mean := 0.0
+	for v, p := range d {
+		mean += float64(v) * p
+	}
+	return mean
+}
+
+// AtLeast returns the probability of rolling n or more.
+func (d Distribution) AtLeast(n int) float64 {
+	total := 0.0
+	for v, p := range d {
+		if v >= n {
+			total += p
+		}
+	}
+	return total
+}
+
+//
+// Evaluation
+//
+
+// dist maps the values of a node to their probabilities.  Values are kept as
+// floats until the end, since libtcod's multipliers are.
+type dist map[float64]float64
+
+type node interface {
+	roll(r Roller) float64
+	dist() dist
+}
+
+type number float64
+
+func (n number) roll(r Roller) float64 {
+	return float64(n)
+}
+
+func (n number) dist() dist {
+	return dist{float64(n): 1}
+}
+
+type negate struct {
+	x node
+}
+
+func (n negate) roll(r Roller) float64 {
+	return -n.x.roll(r)
+}
+
+func (n negate) dist() dist {
+	result := make(dist)
+	for v, p := range n.x.dist() {
+		result[-v] += p
+	}
+	return result
+}
+
+// truncate drops the fraction of a libtcod dice term, as libtcod's int cast
+// does.
+type truncate struct {
+	x node
+}
+
+func (t truncate) roll(r Roller) float64 {
+	return math.Trunc(t.x.roll(r))
+}
+
+func (t truncate) dist() dist {
+	result := make(dist)
+	for v, p := range t.x.dist() {
+		result[math.Trunc(v)] += p
+	}
+	return result
+}
+
+type binary struct {
+	op   byte
+	l, r node
+}
+
+func (b binary) apply(l, r float64) float64 {
+	switch b.op {
+	case '+':
+		return l + r
+	case '-':
+		return l - r
+	case '*':
+		return l * r
+	}
+	if r == 0 {
+		return 0
+	}
+	return l / r
+}
+
+func (b binary) roll(r Roller) float64 {
+	return b.apply(b.l.roll(r), b.r.roll(r))
+}
+
+func (b binary) dist() dist {
+	result := make(dist)
+	rd := b.r.dist()
+	for lv, lp := range b.l.dist() {
+		for rv, rp := range rd {
+			result[b.apply(lv, rv)] += lp * rp
+		}
+	}
+	return result
+}
+
+// group is a number of dice of the same size rolled together.
+type group struct {
+	count, sides int
+	explode      bool
+	reroll       int  // reroll faces up to this
+	keep         int  // the number of dice kept, count to keep them all
+	keepHigh     bool // keep the highest dice, rather than the lowest
+}
+
+func (g *group) rollDie(r Roller) int {
+	total := 0
+	for i := 0; ; i++ {
+		face := r.GetInt(g.reroll+1, g.sides)
+		total += face
+		if !g.explode || face != g.sides || i == MaxExplosions {
+			return total
+		}
+	}
+}
+
+func (g *group) roll(r Roller) float64 {
+	rolls := make([]int, g.count)
+	for i := range rolls {
+		rolls[i] = g.rollDie(r)
+	}
+	if g.keep < g.count {
+		sort.Ints(rolls)
+		if g.keepHigh {
+			rolls = rolls[g.count-g.keep:]
+		} else {
+			rolls = rolls[:g.keep]
+		}
+	}
+	total := 0
+	for _, v := range rolls {
+		total += v
+	}
+	return float64(total)
+}
+
+// run is a range of results, from lo to hi, that are each rolled with
+// probability p.
+type run struct {
+	lo, hi int
+	p      float64
+}
+
+// dieRuns returns the results of a single die, after rerolls and explosions,
+// from lowest to highest.
+func (g *group) dieRuns() []run {
+	p := 1 / float64(g.sides-g.reroll)
+	var runs []run
+	weight := 1.0
+	base := 0
+	for i := 0; ; i++ {
+		if g.reroll+1 < g.sides {
+			runs = append(runs, run{base + g.reroll + 1, base + g.sides - 1, weight * p})
+		}
+		if !g.explode || i == MaxExplosions {
+			return append(runs, run{base + g.sides, base + g.sides, weight * p})
+		}
+		// The highest face rolls again, from a higher base.
+		weight *= p
+		base += g.sides
+	}
+}
+
+// totals returns the number of totals the group's dice could add up to, ignoring
+// any gaps, for limiting the size of its distribution.
+func (g *group) totals() int {
+	runs := g.dieRuns()
+	return g.count*(runs[len(runs)-1].hi-runs[0].lo) + 1
+}
+
+// keepSteps estimates the work keepTotals does, for limiting it.
+func (g *group) keepSteps() int {
+	if g.keep >= g.count {
+		return 0
+	}
+	runs := g.dieRuns()
+	results, hi := 0, runs[len(runs)-1].hi
+	for _, r := range runs {
+		results += r.hi - r.lo + 1
+	}
+	steps := 0
+	for n := 0; n < g.keep; n++ {
+		steps += (n*hi + 1) * (g.keep - n)
+	}
+	return results * steps
+}
+
+func (g *group) dist() dist {
+	var lo int
+	var probs []float64
+	if g.keep >= g.count {
+		lo, probs = g.sumTotals()
+	} else {
+		lo, probs = g.keepTotals()
+	}
+	result := make(dist)
+	for i, p := range probs {
+		if p > 0 {
+			result[float64(lo+i)] = p
+		}
+	}
+	return result
+}
+
+// sumTotals returns the probabilities of the totals of all the dice, from lo
+// up.  The dice are added one at a time, each run of a die's results adding a
+// window of the totals so far, so long runs cost no more than short ones.
+func (g *group) sumTotals() (lo int, probs []float64) {
+	runs := g.dieRuns()
+	dlo, dhi := runs[0].lo, runs[len(runs)-1].hi
+	probs = []float64{1}
+	for i := 0; i < g.count; i++ {
+		next := make([]float64, len(probs)+dhi-dlo)
+		for _, r := range runs {
+			// sums[j] is the chance of the totals that r turns into
+			// next[j+r.lo-dlo].
+			sums := windowSums(probs, r.hi-r.lo+1)
+			offset := r.lo - dlo
+			for j, sum := range sums {
+				next[j+offset] += r.p * sum
+			}
+		}
+		lo, probs = lo+dlo, next
+	}
+	return lo, probs
+}
+
+// windowSums returns the sums of every w consecutive values, with zeros beyond
+// the ends: sums[j] adds up values[j-w+1] to values[j].  It only ever adds, so
+// tiny probabilities aren't lost to rounding, as they would be in differences
+// of running totals.
+func windowSums(values []float64, w int) []float64 {
+	if w == 1 {
+		return values
+	}
+	// Padded with w-1 zeros at each end and split into blocks of w, every
+	// window is either a block or the end of one and the start of the next.
+	padded := make([]float64, len(values)+2*(w-1))
+	copy(padded[w-1:], values)
+	start, end := make([]float64, len(padded)), make([]float64, len(padded))
+	for i, v := range padded {
+		start[i] = v
+		if i%w != 0 {
+			start[i] += start[i-1]
+		}
+	}
+	for i := len(padded) - 1; i >= 0; i-- {
+		end[i] = padded[i]
+		if (i+1)%w != 0 && i+1 < len(padded) {
+			end[i] += end[i+1]
+		}
+	}
+
+	sums := make([]float64, len(values)+w-1)
+	for j := range sums {
+		if j%w == 0 {
+			sums[j] = start[j+w-1]
+		} else {
+			sums[j] = end[j] + start[j+w-1]
+		}
+	}
+	return sums
+}
+
+// keepTotals returns the probabilities of the totals of the kept dice, from lo
+// up.  Results are taken from best to worst, choosing how many of the dice
+// still to be placed show each; the first dice placed are the ones kept, so
+// once keep dice are placed, their total is settled.
+func (g *group) keepTotals() (lo int, probs []float64) {
+	if g.keep == 0 {
+		return 0, []float64{1}
+	}
+	var faces []int
+	var chances []float64
+	for _, r := range g.dieRuns() {
+		for v := r.lo; v <= r.hi; v++ {
+			faces = append(faces, v)
+			chances = append(chances, r.p)
+		}
+	}
+	hi := faces[len(faces)-1]
+	if g.keepHigh {
+		for i, j := 0, len(faces)-1; i < j; i, j = i+1, j-1 {
+			faces[i], faces[j] = faces[j], faces[i]
+			chances[i], chances[j] = chances[j], chances[i]
+		}
+	}
+	// worse[i] is the chance of a die showing faces[i] or a result after it.
+	worse := make([]float64, len(faces)+1)
+	for i := len(faces) - 1; i >= 0; i-- {
+		worse[i] = worse[i+1] + chances[i]
+	}
+
+	// placed[n][s] is the chance that n dice, fewer than keep, have been
+	// placed so far, adding up to s.
+	placed := make([][]float64, g.keep)
+	for n := range placed {
+		placed[n] = make([]float64, n*hi+1)
+	}
+	placed[0][0] = 1
+	probs = make([]float64, g.keep*hi+1)
+	binomials := make([]float64, g.count+1)
+	for i, face := range faces {
+		// Each die still to be placed shows this face with chance q, or a
+		// worse one with chance 1-q.
+		q, notq := chances[i]/worse[i], worse[i+1]/worse[i]
+
+		// Counts only go up, so the counts above n are done with before
+		// dice are placed on them from n.
+		for n := g.keep - 1; n >= 0; n-- {
+			left, need := g.count-n, g.keep-n
+			settled := 0.0
+			for c := 0; c <= left; c++ {
+				binomials[c] = binomial(left, c) * math.Pow(q, float64(c)) * math.Pow(notq, float64(left-c))
+				if c >= need {
+					settled += binomials[c]
+				}
+			}
+			for s, p := range placed[n] {
+				if p == 0 {
+					continue
+				}
+				for c := 1; c < need; c++ {
+					placed[n+c][s+face*c] += p * binomials[c]
+				}
+				probs[s+face*need] += p * settled
+				placed[n][s] = p * binomials[0]
+			}
+		}
+	}
+	return 0, probs
+}
+
+func binomial(n, k int) float64 {
+	result := 1.0
+	for i := 1; i <= k; i++ {
+		result = result * float64(n-k+i) / float64(i)
+	}
+	return result
+}
+
+//
+// Parsing
+//
+
+type parser struct {
+	src string
+	pos int
+}
+
+func (p *parser) errorf(format string, v ...interface{}) error {
+	return fmt.Errorf("dice: %s at column %d of %q", fmt.Sprintf(format, v...), p.pos+1, p.src)
+}
+
+func (p *parser) skipSpace() {
+	for p.pos < len(p.src) && (p.src[p.pos] == ' ' || p.src[p.pos] == '\t') {
+		p.pos++
+	}
+}
+
+// peek returns the next character, after any spaces, or 0 at the end.
+func (p *parser) peek() byte {
+	p.skipSpace()
+	if p.pos < len(p.src) {
+		return p.src[p.pos]
+	}
+	return 0
+}
+
+func (p *parser) expr() (node, error) {
+	l, err := p.term()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		op := p.peek()
+		if op != '+' && op != '-' {
+			return l, nil
+		}
+		p.pos++
+		r, err := p.term()
+		if err != nil {
+			return nil, err
+		}
+		l = binary{op, l, r}
+	}
+}
+
+func (p *parser) term() (node, error) {
+	l, err := p.factor()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		op := p.peek()
+		if op != '*' && op != 'x' && op != '/' {
+			return l, nil
+		}
+		p.pos++
+		if op == 'x' {
+			op = '*'
+		}
+		r, err := p.factor()
+		if err != nil {
+			return nil, err
+		}
+		if multiplier, ok := l.(number); ok && op == '*' {
+			if g, ok := r.(*group); ok {
+				l = p.libtcodDice(multiplier, g)
+				continue
+			}
+		}
+		l = binary{op, l, r}
+	}
+}
+
+// libtcodDice finishes libtcod's "NxMdS+K" form once N and the dice are parsed,
+// taking K if it's a plain number.  libtcod adds K before multiplying by N, and
+// truncates the result.
+func (p *parser) libtcodDice(multiplier number, g *group) node {
+	var sum node = g
+	start := p.pos
+	if op := p.peek(); op == '+' || op == '-' {
+		p.pos++
+		k, err := p.term()
+		if n, ok := k.(number); ok && err == nil {
+			sum = binary{op, g, n}
+		} else {
+			p.pos = start // not part of the dice, so parse it as usual
+		}
+	}
+	return truncate{binary{'*', sum, multiplier}}
+}
+
+func (p *parser) factor() (node, error) {
+	switch c := p.peek(); {
+	case c == '-':
+		p.pos++
+		x, err := p.factor()
+		if err != nil {
+			return nil, err
+		}
+		return negate{x}, nil
+	case c == '(':
+		p.pos++
+		x, err := p.expr()
+		if err != nil {
+			return nil, err
+		}
+		if p.peek() != ')' {
+			return nil, p.errorf("missing )")
+		}
+		p.pos++
+		return x, nil
+	case c == 'd' || c == 'D':
+		return p.dice(1)
+	case c == '.' || isDigit(c):
+		start := p.pos
+		for p.pos < len(p.src) && (isDigit(p.src[p.pos]) || p.src[p.pos] == '.') {
+			p.pos++
+		}
+		text := p.src[start:p.pos]
+		if p.pos < len(p.src) && (p.src[p.pos] == 'd' || p.src[p.pos] == 'D') {
+			count, err := strconv.Atoi(text)
+			if err != nil {
+				p.pos = start
+				return nil, p.errorf("invalid dice count %q", text)
+			}
+			return p.dice(count)
+		}
+		v, err := strconv.ParseFloat(text, 64)
+		if err != nil {
+			p.pos = start
+			return nil, p.errorf("invalid number %q", text)
+		}
+		return number(v), nil
+	case c == 0:
+		return nil, p.errorf("unexpected end")
+	default:
+		return nil, p.errorf("unexpected %q", c)
+	}
+}
+
+// dice parses a dice group from the "d", given its count.
+func (p *parser) dice(count int) (node, error) {
+	p.pos++ // the d
+	g := &group{count: count}
+	if p.pos < len(p.src) && p.src[p.pos] == '%' {
+		p.pos++
+		g.sides = 100
+	} else {
+		sides, ok := p.integer()
+		if !ok {
+			return nil, p.errorf("missing number of sides")
+		}
+		g.sides = sides
+	}
+	if g.count < 1 || g.count > maxCount {
+		return nil, p.errorf("dice count must be from 1 to %d", maxCount)
+	}
+	if g.sides < 1 || g.sides > maxSides {
+		return nil, p.errorf("dice sides must be from 1 to %d", maxSides)
+	}
+	g.keep = g.count
+	g.keepHigh = true
+
+	seen := make(map[byte]bool)
+	for p.pos < len(p.src) {
+		rest := strings.ToLower(p.src[p.pos:])
+		var mod string
+		for _, m := range []string{"!", "r", "kh", "kl", "k", "dh", "dl"} {
+			if strings.HasPrefix(rest, m) {
+				mod = m
+				break
+			}
+		}
+		if mod == "" {
+			break
+		}
+		// Keep and drop modifiers are all the same kind, "k".
+		kind := mod[0]
+		if kind == 'd' {
+			kind = 'k'
+		}
+		if seen[kind] {
+			return nil, p.errorf("repeated modifier %q", mod)
+		}
+		seen[kind] = true
+		p.pos += len(mod)
+		if mod == "!" {
+			g.explode = true
+			continue
+		}
+
+		n, ok := p.integer()
+		if !ok {
+			return nil, p.errorf("missing number after %q", mod)
+		}
+		switch mod {
+		case "r":
+			if n >= g.sides {
+				return nil, p.errorf("can't reroll every face")
+			}
+			g.reroll = n
+		case "k", "kh", "kl", "dh", "dl":
+			if n > g.count {
+				return nil, p.errorf("can't keep or drop more dice than are rolled")
+			}
+			g.keepHigh = mod == "k" || mod == "kh" || mod == "dl"
+			g.keep = n
+			if mod == "dh" || mod == "dl" {
+				g.keep = g.count - n
+			}
+		}
+	}
+	if g.totals() > maxTotals || g.keepSteps() > maxKeepSteps {
+		return nil, p.errorf("dice group is too big to work out its distribution")
+	}
+	return g, nil
+}
+
+func (p *parser) integer() (int, bool) {
+	start := p.pos
+	for p.pos < len(p.src) && isDigit(p.src[p.pos]) {
+		p.pos++
+	}
+	n, err := strconv.Atoi(p.src[start:p.pos])
+	return n, err == nil && n <= math.MaxInt32
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
@@ -0,0 +1,248 @@
+package dice
+
+import (
+	"math"
+	"math/rand"
+	"strings"
+	"testing"
+	"time"
+)
+
+// script is a Roller that returns fixed rolls, clamped to the range asked for.
+type script []int
+
+func (s *script) GetInt(min, max int) int {
+	if len(*s) == 0 {
+		return min
+	}
+	v := (*s)[0]
+	*s = (*s)[1:]
+	if v < min {
+		return min
+	}
+	if v > max {
+		return max
+	}
+	return v
+}
+
+// randRoller rolls with math/rand.
+type randRoller struct {
+	*rand.Rand
+}
+
+func (r randRoller) GetInt(min, max int) int {
+	return min + r.Intn(max-min+1)
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		s        string
+		min, max int
+	}{
+		{"7", 7, 7},
+		{"d6", 1, 6},
+		{"3d6", 3, 18},
+		{"3D6", 3, 18},
+		{"d%", 1, 100},
+		{"2d6+3", 5, 15},
+		{"2d6 - 1d4", -2, 11},
+		{"-1d4", -4, -1},
+		{"(2d6+1d4)*2", 6, 32},
+		{"4d6kh3", 3, 18},
+		{"4d6k3", 3, 18},
+		{"4d6dl1", 3, 18},
+		{"2d20kl1+5", 6, 25},
+		{"3d6dh2", 1, 6},
+		{"d6r2", 3, 6},
+		{"d6!", 1, 6 * (MaxExplosions + 1)},
+		{"2d6!r1k1", 2, 6 * (MaxExplosions + 1)},
+		{"1d6/0", 0, 0},
+		{"1d6/2", 0, 3},
+
+		// libtcod's form adds before multiplying, and truncates.
+		{"0.5x3d5+2", 2, 8},
+		{"0.5x3d5-2", 0, 6},
+		{"0.5x3d5", 1, 7},
+		{"2*3d6+1", 8, 38},
+		{"1+0.5x3d5+2", 3, 9},
+
+		// Anything but a plain number after the dice is parsed as usual.
+		{"(2*3d6)+1", 7, 37},
+		{"2*1d6+3*2", 8, 18},
+		{"2x1d6+1d4", 3, 16},
+		{"3d6*2+1", 7, 37},
+	}
+	for _, test := range tests {
+		e, err := Parse(test.s)
+		if err != nil {
+			t.Errorf("Parse(%q): %v", test.s, err)
+			continue
+		}
+		if min, max := e.Min(), e.Max(); min != test.min || max != test.max {
+			t.Errorf("%q ranges from %d to %d, want %d to %d", test.s, min, max, test.min, test.max)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		s, err string
+	}{
+		{"", "unexpected end"},
+		{"2d", "missing number of sides"},
+		{"d0", "dice sides"},
+		{"0d6", "dice count"},
+		{"1.5d6", "invalid dice count"},
+		{"(1d6", "missing )"},
+		{"1d6+", "unexpected end"},
+		{"1d6 d", "unexpected"},
+		{"3d6r6", "can't reroll every face"},
+		{"3d6k4", "can't keep or drop more dice"},
+		{"3d6k", "missing number after"},
+		{"d6!!", "repeated modifier \"!\""},
+		{"3d6r1r2", "repeated modifier \"r\""},
+		{"4d6kh3kl1", "repeated modifier \"kl\""},
+		{"4d6k3dl1", "repeated modifier \"dl\""},
+		{"100d1000!", "too big"},
+		{"100d1000kh50", "too big"},
+	}
+	for _, test := range tests {
+		_, err := Parse(test.s)
+		if err == nil {
+			t.Errorf("Parse(%q) succeeded", test.s)
+			continue
+		}
+		if !strings.Contains(err.Error(), test.err) || !strings.HasPrefix(err.Error(), "dice: ") {
+			t.Errorf("Parse(%q) error is %q, want one containing %q", test.s, err, test.err)
+		}
+	}
+}
+
+func TestRoll(t *testing.T) {
+	tests := []struct {
+		s     string
+		rolls script
+		want  int
+	}{
+		{"3d6+1", script{1, 2, 3}, 7},
+		{"4d6kh3", script{1, 5, 3, 6}, 14},
+		{"4d6kl1", script{4, 5, 2, 6}, 2},
+		{"4d6dh1", script{4, 5, 2, 6}, 11},
+		{"d6!", script{6, 6, 2}, 14},
+		{"d6r2", script{1}, 3}, // faces 1 and 2 are rerolled, so 3 is the lowest
+		{"0.5x3d5+2", script{5, 5, 5}, 8},
+		{"0.5x3d5+2", script{1, 1, 1}, 2},
+		{"1d6/4", script{5}, 1},
+	}
+	for _, test := range tests {
+		rolls := append(script(nil), test.rolls...)
+		got, err := Roll(&rolls, test.s)
+		if err != nil {
+			t.Errorf("Roll(%q): %v", test.s, err)
+			continue
+		}
+		if got != test.want {
+			t.Errorf("Roll(%q) with %v = %d, want %d", test.s, test.rolls, got, test.want)
+		}
+	}
+}
+
+func TestDistribution(t *testing.T) {
+	tests := []struct {
+		s       string
+		mean    float64
+		results map[int]float64 // some exact probabilities
+	}{
+		{"2d6", 7, map[int]float64{2: 1.0 / 36, 7: 6.0 / 36, 12: 1.0 / 36}},
+		{"3d6", 10.5, map[int]float64{3: 1.0 / 216, 10: 27.0 / 216}},
+		{"d6r2", 4.5, map[int]float64{3: 0.25, 6: 0.25}},
+		{"4d6kh3", 15869.0 / 1296, map[int]float64{3: 1.0 / 1296, 18: 21.0 / 1296}},
+		{"2d20kl1", 2870.0 / 400, map[int]float64{20: 1.0 / 400, 1: 39.0 / 400}},
+		{"2d20kh1", 21 - 2870.0/400, map[int]float64{20: 39.0 / 400, 1: 1.0 / 400}},
+		{"d6!", 3.5 * (1 - math.Pow(1.0/6, MaxExplosions+1)) / (1 - 1.0/6), map[int]float64{6: 0, 7: 1.0 / 36}},
+		{"0.5x3d5+2", 0, map[int]float64{2: 1.0 / 125, 8: 4.0 / 125}}, // 8 is (14+2)/2 or (15+2)/2
+		{"1d4-1d4", 0, map[int]float64{0: 4.0 / 16, 3: 1.0 / 16}},
+	}
+	for _, test := range tests {
+		d := MustParse(test.s).Distribution()
+
+		total := 0.0
+		for _, p := range d {
+			total += p
+		}
+		if math.Abs(total-1) > 1e-9 {
+			t.Errorf("%q: probabilities add up to %v", test.s, total)
+		}
+		if test.mean != 0 && math.Abs(d.Mean()-test.mean) > 1e-9 {
+			t.Errorf("%q: mean is %v, want %v", test.s, d.Mean(), test.mean)
+		}
+		for v, want := range test.results {
+			if math.Abs(d[v]-want) > 1e-12 {
+				t.Errorf("%q: P(%d) is %v, want %v", test.s, v, d[v], want)
+			}
+		}
+	}
+
+	d := MustParse("2d6").Distribution()
+	if p := d.AtLeast(10); math.Abs(p-6.0/36) > 1e-12 {
+		t.Errorf("P(2d6 >= 10) is %v, want %v", p, 6.0/36)
+	}
+	if results := d.Results(); len(results) != 11 || results[0] != 2 || results[10] != 12 {
+		t.Errorf("2d6 results are %v", results)
+	}
+}
+
+// TestDistributionMatchesRolls checks the exact distributions against many
+// random rolls.
+func TestDistributionMatchesRolls(t *testing.T) {
+	r := randRoller{rand.New(rand.NewSource(1))}
+	const n = 200000
+	for _, s := range []string{"3d6", "4d6kh3", "5d8dl2", "3d10kl2", "2d6!", "d8r3", "0.5x3d5+2", "2d6/3"} {
+		e := MustParse(s)
+		d := e.Distribution()
+		counts := make(map[int]int)
+		for i := 0; i < n; i++ {
+			counts[e.Roll(r)]++
+		}
+		for v, c := range counts {
+			p, ok := d[v]
+			if !ok {
+				t.Errorf("%q rolled %d, which isn't in its distribution", s, v)
+				continue
+			}
+			// Allow five standard deviations.
+			if got, sd := float64(c)/n, math.Sqrt(p*(1-p)/n); math.Abs(got-p) > 5*sd+1e-4 {
+				t.Errorf("%q rolled %d %v of the time, want %v", s, v, got, p)
+			}
+		}
+	}
+}
+
+// TestDistributionTime checks that the largest groups allowed are quick to work
+// out.
+func TestDistributionTime(t *testing.T) {
+	for _, s := range []string{"100d1000", "2d1000!", "10d1000!", "100d100kh50", "100d6!kh50", "50d1000kl10"} {
+		done := make(chan Distribution, 1)
+		go func(s string) {
+			done <- MustParse(s).Distribution()
+		}(s)
+
+		select {
+		case d := <-done:
+			total := 0.0
+			for _, p := range d {
+				total += p
+			}
+			if math.Abs(total-1) > 1e-9 {
+				t.Errorf("%q: probabilities add up to %v", s, total)
+			}
+		case <-time.After(10 * time.Second):
+			t.Fatalf("%q took over 10 seconds to work out", s)
+		}
+	}
+
+	if d := MustParse("100d1000").Distribution(); d.Min() != 100 || d.Max() != 100000 || len(d) != 99901 {
+		t.Errorf("100d1000 has %d results from %d to %d", len(d), d.Min(), d.Max())
+	}
+}
@@ -0,0 +1,213 @@
+package tcod
+
+/*
+ #include "include/libtcod.h"
+*/
+import "C"
+
+import (
+	"errors"
+	"math"
+)
+
+//
+// Dijkstra maps
+//
+
+// DijkstraUnreachable is the distance of cells a DijkstraMap couldn't reach from
+// any source.
+const DijkstraUnreachable = math.MaxInt32
+
+// DijkstraSource is a goal a DijkstraMap measures distances from.  Value is the
+// goal's starting distance: lower values attract more strongly, so a chest worth
+// more than another can be given a more negative value.
+type DijkstraSource struct {
+	X, Y  int
+	Value int
+}
+
+// DijkstraMap is a distance field measured from any number of weighted sources,
+// often called a goal map.  Monsters head for the nearest goal by walking
+// Downhill, and Flee turns the map into one that leads away from the sources.
+//
+// Each cell has a cost to step into it; a cost of 0 blocks the cell.  Steps are
+// multiplied by the cardinal or diagonal cost, and diagonal moves are disabled if
+// the diagonal cost is 0.
+type DijkstraMap struct {
+	w, h               int
+	cardinal, diagonal int
+	cost               []int
+	dist               []int
+}
+
+// NewDijkstraMap creates a w x h map where every cell costs 1 to enter.  A
+// cardinal cost of 2 and diagonal cost of 3 approximates true distances.
+func NewDijkstraMap(w, h int, cardinal, diagonal int) *DijkstraMap {
+	dm := &DijkstraMap{
+		w:        w,
+		h:        h,
+		cardinal: cardinal,
+		diagonal: diagonal,
+		cost:     make([]int, w*h),
+		dist:     make([]int, w*h),
+	}
+	for i := range dm.cost {
+		dm.cost[i] = 1
+		dm.dist[i] = DijkstraUnreachable
+	}
+	return dm
+}
+
+// NewDijkstraMapUsingMap creates a map the size of m, where walkable cells cost
+// 1 to enter and other cells are blocked.
+func NewDijkstraMapUsingMap(m *Map, cardinal, diagonal int) *DijkstraMap {
+	dm := NewDijkstraMap(m.GetWidth(), m.GetHeight(), cardinal, diagonal)
+	for y := 0; y < dm.h; y++ {
+		for x := 0; x < dm.w; x++ {
+			if !m.IsWalkable(x, y) {
+				dm.cost[y*dm.w+x] = 0
+			}
+		}
+	}
+	return dm
+}
+
+func (dm *DijkstraMap) GetWidth() int {
+	return dm.w
+}
+
+func (dm *DijkstraMap) GetHeight() int {
+	return dm.h
+}
+
+func (dm *DijkstraMap) inBounds(x, y int) bool {
+	return x >= 0 && y >= 0 && x < dm.w && y < dm.h
+}
+
+// SetCost sets the cost of stepping into a cell, such as a higher cost for swamp
+// or 0 for a wall.
+func (dm *DijkstraMap) SetCost(x, y, cost int) {
+	if dm.inBounds(x, y) {
+		dm.cost[y*dm.w+x] = cost
+	}
+}
+
+func (dm *DijkstraMap) GetCost(x, y int) int {
+	if !dm.inBounds(x, y) {
+		return 0
+	}
+	return dm.cost[y*dm.w+x]
+}
+
+// GetDistance returns the distance to the nearest source, after its value is
+// taken into account, or DijkstraUnreachable.
+func (dm *DijkstraMap) GetDistance(x, y int) int {
+	if !dm.inBounds(x, y) {
+		return DijkstraUnreachable
+	}
+	return dm.dist[y*dm.w+x]
+}
+
+// Compute measures the distance of every cell from sources, replacing any
+// earlier distances.  Sources outside the map are ignored.
+func (dm *DijkstraMap) Compute(sources []DijkstraSource) error {
+	frontier := C.TCOD_frontier_new(2)
+	if frontier == nil {
+		return errors.New(C.GoString(C.TCOD_get_error()))
+	}
+	defer C.TCOD_frontier_delete(frontier)
+
+	for i := range dm.dist {
+		dm.dist[i] = DijkstraUnreachable
+	}
+
+	var index [2]C.int
+	push := func(x, y, dist int) error {
+		dm.dist[y*dm.w+x] = dist
+		index[0], index[1] = C.int(x), C.int(y)
+		return toError(C.TCOD_frontier_push(frontier, &index[0], C.int(dist), C.int(dist)))
+	}
+
+	for _, source := range sources {
+		if dm.inBounds(source.X, source.Y) && source.Value < dm.dist[source.Y*dm.w+source.X] {
+			if err := push(source.X, source.Y, source.Value); err != nil {
+				return err
+			}
+		}
+	}
+
+	for C.TCOD_frontier_size(frontier) > 0 {
+		if err := toError(C.TCOD_frontier_pop(frontier)); err != nil {
+			return err
+		}
+		x, y := int(frontier.active_index[0]), int(frontier.active_index[1])
+		dist := int(frontier.active_dist)
+		if dist > dm.dist[y*dm.w+x] {
+			continue // a shorter route was found after this one was queued
+		}
+
+		for _, dir := range dijkstraDirections {
+			nx, ny := x+dir[0], y+dir[1]
+			step := dm.cardinal
+			if dir[0] != 0 && dir[1] != 0 {
+				step = dm.diagonal
+			}
+			if step <= 0 || !dm.inBounds(nx, ny) || dm.cost[ny*dm.w+nx] <= 0 {
+				continue
+			}
+			next := dist + step*dm.cost[ny*dm.w+nx]
+			if next < dm.dist[ny*dm.w+nx] {
+				if err := push(nx, ny, next); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// dijkstraDirections are the steps to a cell's neighbours, cardinal directions
+// first.
+var dijkstraDirections = [8][2]int{
+	{0, -1}, {1, 0}, {0, 1}, {-1, 0},
+	{1, -1}, {1, 1}, {-1, 1}, {-1, -1},
+}
+
+// Downhill returns the step towards the neighbour with the lowest distance, for
+// moving towards the nearest source.  It returns false if no neighbour is lower
+// than x, y, such as at a source.
+func (dm *DijkstraMap) Downhill(x, y int) (dx, dy int, ok bool) {
+	best := dm.GetDistance(x, y)
+	if best == DijkstraUnreachable {
+		return 0, 0, false
+	}
+	for _, dir := range dijkstraDirections {
+		if dir[0] != 0 && dir[1] != 0 && dm.diagonal <= 0 {
+			continue
+		}
+		nx, ny := x+dir[0], y+dir[1]
+		if dm.GetCost(nx, ny) <= 0 {
+			continue
+		}
+		if d := dm.GetDistance(nx, ny); d < best {
+			best, dx, dy, ok = d, dir[0], dir[1], true
+		}
+	}
+	return
+}
+
+// Flee turns the map into one leading away from its sources, by multiplying every
+// reachable distance by coefficient and computing the map again with each cell as
+// a source.  The coefficient should be negative; around -1.2 makes fleeing
+// monsters prefer escape routes over corners.  Walk Downhill to flee.
+func (dm *DijkstraMap) Flee(coefficient float32) error {
+	var sources []DijkstraSource
+	for y := 0; y < dm.h; y++ {
+		for x := 0; x < dm.w; x++ {
+			if d := dm.dist[y*dm.w+x]; d != DijkstraUnreachable {
+				sources = append(sources, DijkstraSource{x, y, int(float32(d) * coefficient)})
+			}
+		}
+	}
+	return dm.Compute(sources)
+}
@@ -0,0 +1,161 @@
+package tcod
+
+import (
+	"reflect"
+	"testing"
+)
+
+const unreachable = DijkstraUnreachable
+
+// newDijkstraMapFrom creates a map from rows of text, where # is blocked and a
+// digit is the cost of the cell; anything else costs 1.
+func newDijkstraMapFrom(cardinal, diagonal int, rows ...string) *DijkstraMap {
+	dm := NewDijkstraMap(len(rows[0]), len(rows), cardinal, diagonal)
+	for y, row := range rows {
+		for x, c := range row {
+			switch {
+			case c == '#':
+				dm.SetCost(x, y, 0)
+			case c >= '0' && c <= '9':
+				dm.SetCost(x, y, int(c-'0'))
+			}
+		}
+	}
+	return dm
+}
+
+// distances returns the map's distances in row-major order.
+func distances(dm *DijkstraMap) []int {
+	result := make([]int, 0, dm.GetWidth()*dm.GetHeight())
+	for y := 0; y < dm.GetHeight(); y++ {
+		for x := 0; x < dm.GetWidth(); x++ {
+			result = append(result, dm.GetDistance(x, y))
+		}
+	}
+	return result
+}
+
+func TestDijkstraMapCompute(t *testing.T) {
+	tests := []struct {
+		name               string
+		rows               []string
+		cardinal, diagonal int
+		sources            []DijkstraSource
+		want               []int
+	}{
+		{"corridor", []string{"....."}, 2, 3,
+			[]DijkstraSource{{0, 0, 0}},
+			[]int{0, 2, 4, 6, 8}},
+		{"diagonals", []string{"...", "...", "..."}, 2, 3,
+			[]DijkstraSource{{1, 1, 0}},
+			[]int{3, 2, 3, 2, 0, 2, 3, 2, 3}},
+		{"no diagonals", []string{"...", "...", "..."}, 2, 0,
+			[]DijkstraSource{{1, 1, 0}},
+			[]int{4, 2, 4, 2, 0, 2, 4, 2, 4}},
+		{"weighted sources", []string{"......."}, 1, 0,
+			[]DijkstraSource{{0, 0, 0}, {6, 0, -4}},
+			[]int{0, 1, 0, -1, -2, -3, -4}},
+		{"repeated source", []string{"..."}, 1, 1,
+			[]DijkstraSource{{0, 0, 5}, {0, 0, 2}, {0, 0, 7}},
+			[]int{2, 3, 4}},
+		{"walls", []string{".#.", ".#.", "..."}, 1, 0,
+			[]DijkstraSource{{0, 0, 0}},
+			[]int{0, unreachable, 6, 1, unreachable, 5, 2, 3, 4}},
+		{"costs", []string{".#.", ".#.", ".3."}, 1, 0,
+			[]DijkstraSource{{0, 0, 0}},
+			[]int{0, unreachable, 8, 1, unreachable, 7, 2, 5, 6}},
+		{"walled off", []string{".#.", "##.", "..."}, 1, 1,
+			[]DijkstraSource{{0, 0, 0}},
+			[]int{0, unreachable, unreachable, unreachable, unreachable, unreachable, unreachable,
+				unreachable, unreachable}},
+		{"sources outside", []string{"...", "..."}, 1, 1,
+			[]DijkstraSource{{-1, 0, 0}, {3, 0, 0}, {0, 2, 0}},
+			[]int{unreachable, unreachable, unreachable, unreachable, unreachable, unreachable}},
+		{"sources inside and outside", []string{"...", "..."}, 1, 1,
+			[]DijkstraSource{{-1, 0, 0}, {2, 1, 0}},
+			[]int{2, 1, 1, 2, 1, 0}},
+	}
+	for _, test := range tests {
+		dm := newDijkstraMapFrom(test.cardinal, test.diagonal, test.rows...)
+		if err := dm.Compute(test.sources); err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		if got := distances(dm); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: distances are %v, want %v", test.name, got, test.want)
+		}
+	}
+
+	// Computing again replaces the old distances.
+	dm := newDijkstraMapFrom(1, 0, "...")
+	dm.Compute([]DijkstraSource{{0, 0, 0}})
+	dm.Compute([]DijkstraSource{{2, 0, 0}})
+	if got, want := distances(dm), []int{2, 1, 0}; !reflect.DeepEqual(got, want) {
+		t.Errorf("recomputed distances are %v, want %v", got, want)
+	}
+	if d := dm.GetDistance(3, 0); d != unreachable {
+		t.Errorf("the distance outside the map is %d", d)
+	}
+}
+
+func TestDijkstraMapDownhill(t *testing.T) {
+	dm := newDijkstraMapFrom(2, 3, "...", "...", "...")
+	dm.Compute([]DijkstraSource{{0, 0, 0}})
+	if dx, dy, ok := dm.Downhill(2, 2); dx != -1 || dy != -1 || !ok {
+		t.Errorf("downhill from the far corner is %d,%d %v, want the diagonal", dx, dy, ok)
+	}
+	if _, _, ok := dm.Downhill(0, 0); ok {
+		t.Error("there's a way downhill from the source")
+	}
+
+	// Without diagonals, walk around the wall.
+	dm = newDijkstraMapFrom(1, 0, ".#.", ".#.", "...")
+	dm.Compute([]DijkstraSource{{0, 0, 0}})
+	x, y, steps := 2, 0, 0
+	for {
+		dx, dy, ok := dm.Downhill(x, y)
+		if !ok {
+			break
+		}
+		if dx != 0 && dy != 0 {
+			t.Fatalf("stepped diagonally from %d,%d", x, y)
+		}
+		x, y, steps = x+dx, y+dy, steps+1
+		if dm.GetCost(x, y) == 0 {
+			t.Fatalf("stepped into the wall at %d,%d", x, y)
+		}
+	}
+	if x != 0 || y != 0 || steps != 6 {
+		t.Errorf("walked downhill to %d,%d in %d steps, want 0,0 in 6", x, y, steps)
+	}
+	if _, _, ok := dm.Downhill(1, 0); ok {
+		t.Error("there's a way downhill from inside the wall")
+	}
+}
+
+func TestDijkstraMapFlee(t *testing.T) {
+	dm := newDijkstraMapFrom(1, 0, ".......")
+	dm.Compute([]DijkstraSource{{1, 0, 0}})
+	if err := dm.Flee(-1.2); err != nil {
+		t.Fatal(err)
+	}
+	// The far end of the corridor is the best place to flee to, so even the
+	// cell on the near side of the source leads past it.
+	if got, want := distances(dm), []int{-1, -1, -2, -3, -4, -5, -6}; !reflect.DeepEqual(got, want) {
+		t.Errorf("flee distances are %v, want %v", got, want)
+	}
+
+	x := 1
+	for {
+		dx, _, ok := dm.Downhill(x, 0)
+		if !ok {
+			break
+		}
+		if dx != 1 {
+			t.Fatalf("fled from %d towards the source", x)
+		}
+		x += dx
+	}
+	if x != 6 {
+		t.Errorf("fled to %d, want 6", x)
+	}
+}
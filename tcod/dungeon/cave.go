@@ -0,0 +1,176 @@
+package dungeon
+
+import (
+	"github.com/sbowman/tcod/tcod"
+)
+
+//
+// Cellular automata caves
+//
+
+// CaveRegions says what's done with the separate open areas the automaton leaves.
+type CaveRegions int
+
+const (
+	// KeepLargest fills in every area but the largest.
+	KeepLargest CaveRegions = iota
+
+	// Tunnel digs corridors joining every area to the largest.
+	Tunnel
+)
+
+// CaveConfig controls the cave's growth.
+type CaveConfig struct {
+	// FillRatio is the chance of each cell starting as wall, from 0 to 1.
+	FillRatio float32
+
+	// Birth is the number of walls among its 8 neighbours that turns an open
+	// cell into wall, and Survival the number a wall needs to stay wall.
+	// Cells beyond the edges of the map count as walls.
+	Birth, Survival int
+
+	// Iterations is the number of times the rules are applied.
+	Iterations int
+
+	Regions CaveRegions
+}
+
+// DefaultCaveConfig returns the classic 4-5 rule.
+func DefaultCaveConfig() CaveConfig {
+	return CaveConfig{
+		FillRatio:  0.45,
+		Birth:      5,
+		Survival:   4,
+		Iterations: 5,
+		Regions:    KeepLargest,
+	}
+}
+
+// GenerateCave grows a w x h cave, taking its random numbers from random.  The
+// cave is made of Floor cells, with Corridor cells for the tunnels joining its
+// areas, and has no Rooms.
+func GenerateCave(w, h int, config CaveConfig, random *tcod.Random) *Dungeon {
+	d := &Dungeon{
+		Width:  w,
+		Height: h,
+		Tiles:  make([]Tile, w*h),
+	}
+
+	for y := 1; y < h-1; y++ {
+		for x := 1; x < w-1; x++ {
+			if random.GetFloat(0, 1) >= config.FillRatio {
+				d.Tiles[y*w+x] = Floor
+			}
+		}
+	}
+
+	next := make([]Tile, w*h)
+	for i := 0; i < config.Iterations; i++ {
+		for y := 1; y < h-1; y++ {
+			for x := 1; x < w-1; x++ {
+				walls := d.wallsAround(x, y)
+				if d.Tiles[y*w+x] == Wall {
+					if walls >= config.Survival {
+						next[y*w+x] = Wall
+					} else {
+						next[y*w+x] = Floor
+					}
+				} else if walls >= config.Birth {
+					next[y*w+x] = Wall
+				} else {
+					next[y*w+x] = Floor
+				}
+			}
+		}
+		d.Tiles, next = next, d.Tiles
+	}
+
+	regions := d.regions()
+	if len(regions) > 1 {
+		switch config.Regions {
+		case KeepLargest:
+			for _, region := range regions[1:] {
+				for _, i := range region {
+					d.Tiles[i] = Wall
+				}
+			}
+		case Tunnel:
+			d.tunnel(regions)
+		}
+	}
+	return d
+}
+
+// GenerateCaveFromSeed grows a w x h cave with its own random number generator,
+// seeded with seed.
+func GenerateCaveFromSeed(w, h int, config CaveConfig, seed uint32) *Dungeon {
+	return GenerateCave(w, h, config, tcod.NewRandomFromSeed(seed))
+}
+
+// wallsAround counts the walls among a cell's 8 neighbours.
+func (d *Dungeon) wallsAround(x, y int) int {
+	walls := 0
+	for dy := -1; dy <= 1; dy++ {
+		for dx := -1; dx <= 1; dx++ {
+			if (dx != 0 || dy != 0) && !d.IsFloor(x+dx, y+dy) {
+				walls++
+			}
+		}
+	}
+	return walls
+}
+
+// regions flood fills the open areas, returning the cells of each, largest
+// first.  Cells are only joined to their 4 orthogonal neighbours, so areas that
+// just touch at a corner are separate.
+func (d *Dungeon) regions() [][]int {
+	seen := make([]bool, len(d.Tiles))
+	var regions [][]int
+	for start := range d.Tiles {
+		if seen[start] || d.Tiles[start] == Wall {
+			continue
+		}
+
+		seen[start] = true
+		region := []int{start}
+		for i := 0; i < len(region); i++ {
+			x, y := region[i]%d.Width, region[i]/d.Width
+			for _, dir := range [4][2]int{{0, -1}, {1, 0}, {0, 1}, {-1, 0}} {
+				nx, ny := x+dir[0], y+dir[1]
+				if !d.IsFloor(nx, ny) || seen[ny*d.Width+nx] {
+					continue
+				}
+				seen[ny*d.Width+nx] = true
+				region = append(region, ny*d.Width+nx)
+			}
+		}
+		regions = append(regions, region)
+	}
+
+	// A stable insertion sort, so equal regions stay in map order.
+	for i := 1; i < len(regions); i++ {
+		for j := i; j > 0 && len(regions[j]) > len(regions[j-1]); j-- {
+			regions[j], regions[j-1] = regions[j-1], regions[j]
+		}
+	}
+	return regions
+}
+
+// tunnel joins each region to the nearest cell of the largest, along with the
+// regions already joined to it.
+func (d *Dungeon) tunnel(regions [][]int) {
+	joined := append([]int(nil), regions[0]...)
+	for _, region := range regions[1:] {
+		x1, y1 := region[0]%d.Width, region[0]/d.Width
+		best, x2, y2 := -1, 0, 0
+		for _, i := range joined {
+			x, y := i%d.Width, i/d.Width
+			if dist := (x-x1)*(x-x1) + (y-y1)*(y-y1); best < 0 || dist < best {
+				best, x2, y2 = dist, x, y
+			}
+		}
+		d.hline(x1, x2, y1)
+		d.vline(x2, y1, y2)
+		joined = append(joined, region...)
+	}
+}
@@ -0,0 +1,133 @@
+package dungeon
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+// draw renders the dungeon as text, one row per line.
+func draw(d *Dungeon) string {
+	var b strings.Builder
+	for y := 0; y < d.Height; y++ {
+		for x := 0; x < d.Width; x++ {
+			switch d.Get(x, y) {
+			case Wall:
+				b.WriteByte('#')
+			case Room:
+				b.WriteByte('.')
+			case Corridor:
+				b.WriteByte(',')
+			case Floor:
+				b.WriteByte(' ')
+			}
+		}
+		b.WriteByte('\n')
+	}
+	return b.String()
+}
+
+// golden compares got with testdata/name, or rewrites the file with -update.
+func golden(t *testing.T, name, got string) {
+	t.Helper()
+	path := filepath.Join("testdata", name)
+	if *update {
+		if err := os.MkdirAll("testdata", 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(got), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("%v; run the test with -update to create it", err)
+	}
+	if got != string(want) {
+		t.Errorf("output differs from %s; got\n%s\nwant\n%s", path, got, want)
+	}
+}
+
+// checkConnected fails the test unless every open cell can be reached from every
+// other one, moving orthogonally.
+func checkConnected(t *testing.T, d *Dungeon) {
+	t.Helper()
+	start, open := -1, 0
+	for i, tile := range d.Tiles {
+		if tile != Wall {
+			if start < 0 {
+				start = i
+			}
+			open++
+		}
+	}
+	if open == 0 {
+		t.Fatal("the cave has no open cells")
+	}
+
+	seen := map[int]bool{start: true}
+	queue := []int{start}
+	for len(queue) > 0 {
+		x, y := queue[0]%d.Width, queue[0]/d.Width
+		queue = queue[1:]
+		for _, dir := range [4][2]int{{0, -1}, {1, 0}, {0, 1}, {-1, 0}} {
+			nx, ny := x+dir[0], y+dir[1]
+			if i := ny*d.Width + nx; d.IsFloor(nx, ny) && !seen[i] {
+				seen[i] = true
+				queue = append(queue, i)
+			}
+		}
+	}
+	if len(seen) != open {
+		t.Errorf("only %d of %d open cells are connected:\n%s", len(seen), open, draw(d))
+	}
+}
+
+func TestGenerateCaveGolden(t *testing.T) {
+	// Seed 2 leaves a few small areas apart from the main cave, so the two
+	// ways of dealing with them give different maps.
+	config := DefaultCaveConfig()
+	golden(t, "cave_keep_largest.golden", draw(GenerateCaveFromSeed(60, 30, config, 2)))
+
+	config.Regions = Tunnel
+	golden(t, "cave_tunnel.golden", draw(GenerateCaveFromSeed(60, 30, config, 2)))
+}
+
+func TestGenerateCaveDeterministic(t *testing.T) {
+	config := DefaultCaveConfig()
+	first := draw(GenerateCaveFromSeed(60, 30, config, 99))
+	if again := draw(GenerateCaveFromSeed(60, 30, config, 99)); again != first {
+		t.Errorf("the same seed made two caves:\n%s\n%s", first, again)
+	}
+	if other := draw(GenerateCaveFromSeed(60, 30, config, 100)); other == first {
+		t.Error("different seeds made the same cave")
+	}
+}
+
+func TestGenerateCaveConnected(t *testing.T) {
+	for _, regions := range []CaveRegions{KeepLargest, Tunnel} {
+		for seed := uint32(1); seed <= 20; seed++ {
+			config := DefaultCaveConfig()
+			config.Regions = regions
+			d := GenerateCaveFromSeed(80, 40, config, seed)
+
+			for x := 0; x < d.Width; x++ {
+				if d.IsFloor(x, 0) || d.IsFloor(x, d.Height-1) {
+					t.Fatalf("seed %d: the top or bottom edge is open:\n%s", seed, draw(d))
+				}
+			}
+			for y := 0; y < d.Height; y++ {
+				if d.IsFloor(0, y) || d.IsFloor(d.Width-1, y) {
+					t.Fatalf("seed %d: the left or right edge is open:\n%s", seed, draw(d))
+				}
+			}
+			checkConnected(t, d)
+		}
+	}
+}
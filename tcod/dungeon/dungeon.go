@@ -0,0 +1,397 @@
+// Package dungeon generates levels.  Generate builds rooms-and-corridors
+// dungeons by splitting the map with a tcod.Bsp tree, digging a room in each
+// leaf, and joining sibling nodes with corridors, so every room can be reached.
+// GenerateCave grows caves with a cellular automaton.
+//
+// Generation only draws numbers from the tcod.Random it's given, so the same
+// seed and config always produce the same level.
+package dungeon
+
+import (
+	"github.com/sbowman/tcod/tcod"
+)
+
+// Tile is what fills a cell of the dungeon.
+type Tile uint8
+
+const (
+	Wall Tile = iota
+	Room
+	Corridor
+	Floor // open ground outside a room, such as a cave
+)
+
+// CorridorStyle is the shape of the corridors joining rooms.
+type CorridorStyle int
+
+const (
+	// CorridorZ joins rooms that face each other with a straight corridor,
+	// and otherwise bends twice, halfway between the rooms.
+	CorridorZ CorridorStyle = iota
+
+	// CorridorL joins the rooms with a single bend.
+	CorridorL
+)
+
+// Rect is a room's floor, not including its walls.
+type Rect struct {
+	X, Y, W, H int
+}
+
+// Center returns the cell in the middle of the rectangle.
+func (r Rect) Center() (x, y int) {
+	return r.X + r.W/2, r.Y + r.H/2
+}
+
+// Contains returns true if the cell is in the rectangle.
+func (r Rect) Contains(x, y int) bool {
+	return x >= r.X && y >= r.Y && x < r.X+r.W && y < r.Y+r.H
+}
+
+// Config controls the dungeon's layout.
+type Config struct {
+	// Depth is how many times the map is split in two, giving up to 2^Depth
+	// rooms.
+	Depth int
+
+	// MinRoomSize and MaxRoomSize limit the width and height of rooms.  If
+	// MaxRoomSize is 0, rooms fill their part of the map.
+	MinRoomSize, MaxRoomSize int
+
+	// Padding is the number of wall cells kept around each room, inside its
+	// part of the map.  The edges of the map are always wall.
+	Padding int
+
+	// MaxRatio limits how much longer than wide, or wide than long, the parts
+	// of the map are allowed to get before they're split the other way.
+	MaxRatio float32
+
+	Corridors CorridorStyle
+
+	// ExtraLoops is the number of extra corridors dug between nearby rooms
+	// that aren't already joined, so the dungeon isn't a tree.
+	ExtraLoops int
+}
+
+// DefaultConfig returns the layout used by the BSP sample.
+func DefaultConfig() Config {
+	return Config{
+		Depth:       8,
+		MinRoomSize: 4,
+		Padding:     1,
+		MaxRatio:    1.5,
+		Corridors:   CorridorZ,
+	}
+}
+
+// Dungeon is a generated dungeon.  Connections lists the pairs of rooms, by index
+// into Rooms, that corridors were dug between; corridors may also cut through
+// other rooms on the way.
+type Dungeon struct {
+	Width, Height int
+	Tiles         []Tile
+	Rooms         []Rect
+	Connections   [][2]int
+}
+
+// Generate builds a w x h dungeon, taking its random numbers from random.
+func Generate(w, h int, config Config, random *tcod.Random) *Dungeon {
+	d := &Dungeon{
+		Width:  w,
+		Height: h,
+		Tiles:  make([]Tile, w*h),
+	}
+	g := &generator{
+		Dungeon: d,
+		config:  config,
+		random:  random,
+		rooms:   make(map[*tcod.Bsp][]int),
+	}
+
+	minSize := config.MinRoomSize + 2*config.Padding
+	maxRatio := config.MaxRatio
+	if maxRatio <= 0 {
+		maxRatio = 1.5
+	}
+	bsp := tcod.NewBspWithSize(0, 0, w, h)
+	bsp.SplitRecursive(random, config.Depth, minSize, minSize, maxRatio, maxRatio)
+	bsp.TraversePostOrder(g.visit, nil)
+
+	for i := 0; i < config.ExtraLoops; i++ {
+		g.addLoop()
+	}
+	return d
+}
+
+// GenerateFromSeed builds a w x h dungeon with its own random number generator,
+// seeded with seed.
+func GenerateFromSeed(w, h int, config Config, seed uint32) *Dungeon {
+	return Generate(w, h, config, tcod.NewRandomFromSeed(seed))
+}
+
+func (d *Dungeon) inBounds(x, y int) bool {
+	return x >= 0 && y >= 0 && x < d.Width && y < d.Height
+}
+
+// Get returns the tile at x, y, or Wall outside the dungeon.
+func (d *Dungeon) Get(x, y int) Tile {
+	if !d.inBounds(x, y) {
+		return Wall
+	}
+	return d.Tiles[y*d.Width+x]
+}
+
+// IsFloor returns true for any cell that isn't a wall.
+func (d *Dungeon) IsFloor(x, y int) bool {
+	return d.Get(x, y) != Wall
+}
+
+// RoomAt returns the index of the room containing x, y, or -1.
+func (d *Dungeon) RoomAt(x, y int) int {
+	for i, room := range d.Rooms {
+		if room.Contains(x, y) {
+			return i
+		}
+	}
+	return -1
+}
+
+// Neighbours returns the rooms joined to the room by a corridor.
+func (d *Dungeon) Neighbours(room int) []int {
+	var result []int
+	for _, c := range d.Connections {
+		if c[0] == room {
+			result = append(result, c[1])
+		} else if c[1] == room {
+			result = append(result, c[0])
+		}
+	}
+	return result
+}
+
+// Fill sets the cells of m from the dungeon, making floors transparent and
+// walkable and walls neither.  Cells beyond the dungeon are left alone.
+func (d *Dungeon) Fill(m *tcod.Map) {
+	w, h := min(d.Width, m.GetWidth()), min(d.Height, m.GetHeight())
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			floor := d.IsFloor(x, y)
+			m.SetProperties(x, y, floor, floor)
+		}
+	}
+}
+
+// generator holds the state of a dungeon being built.
+type generator struct {
+	*Dungeon
+	config Config
+	random *tcod.Random
+
+	// rooms holds the rooms dug in each BSP node and the nodes below it.
+	rooms map[*tcod.Bsp][]int
+}
+
+// visit digs a room in each leaf, and joins the two halves of every other node.
+// The tree is walked in post-order, so a node's sons are done first.
+func (g *generator) visit(node *tcod.Bsp, userData interface{}) bool {
+	if node.IsLeaf() {
+		if room, ok := g.placeRoom(node); ok {
+			g.rooms[node] = []int{g.digRoom(room)}
+		}
+		return true
+	}
+
+	left, right := g.rooms[node.Left()], g.rooms[node.Right()]
+	if len(left) > 0 && len(right) > 0 {
+		a, b := g.closest(left, right)
+		g.digCorridor(a, b, node.Horizontal)
+	}
+	g.rooms[node] = append(append([]int(nil), left...), right...)
+	return true
+}
+
+// placeRoom picks a room inside the node, keeping clear of the map's edges.
+func (g *generator) placeRoom(node *tcod.Bsp) (Rect, bool) {
+	pad := g.config.Padding
+	minx, maxx := max(1, node.X+pad), min(g.Width-2, node.X+node.W-1-pad)
+	miny, maxy := max(1, node.Y+pad), min(g.Height-2, node.Y+node.H-1-pad)
+	if maxx < minx || maxy < miny {
+		return Rect{}, false
+	}
+
+	w := g.roomSize(maxx - minx + 1)
+	h := g.roomSize(maxy - miny + 1)
+	x := g.random.GetInt(minx, maxx-w+1)
+	y := g.random.GetInt(miny, maxy-h+1)
+	return Rect{x, y, w, h}, true
+}
+
+// roomSize picks the size of a room with available cells to fit in.
+func (g *generator) roomSize(available int) int {
+	if g.config.MaxRoomSize <= 0 || available <= g.config.MinRoomSize {
+		return available
+	}
+	return g.random.GetInt(g.config.MinRoomSize, min(g.config.MaxRoomSize, available))
+}
+
+func (g *generator) digRoom(room Rect) int {
+	for y := room.Y; y < room.Y+room.H; y++ {
+		for x := room.X; x < room.X+room.W; x++ {
+			g.Tiles[y*g.Width+x] = Room
+		}
+	}
+	g.Rooms = append(g.Rooms, room)
+	return len(g.Rooms) - 1
+}
+
+// closest returns the pair of rooms, one from each list, with the nearest
+// centres.
+func (g *generator) closest(rooms1, rooms2 []int) (a, b int) {
+	best := -1
+	for _, r1 := range rooms1 {
+		for _, r2 := range rooms2 {
+			if d := g.distance(r1, r2); best < 0 || d < best {
+				best, a, b = d, r1, r2
+			}
+		}
+	}
+	return
+}
+
+func (g *generator) distance(r1, r2 int) int {
+	x1, y1 := g.Rooms[r1].Center()
+	x2, y2 := g.Rooms[r2].Center()
+	return (x2-x1)*(x2-x1) + (y2-y1)*(y2-y1)
+}
+
+// connected returns true if a corridor was already dug between the rooms.
+func (g *generator) connected(r1, r2 int) bool {
+	for _, c := range g.Connections {
+		if (c[0] == r1 && c[1] == r2) || (c[0] == r2 && c[1] == r1) {
+			return true
+		}
+	}
+	return false
+}
+
+// addLoop joins a random room to the nearest room it isn't joined to yet.
+func (g *generator) addLoop() {
+	if len(g.Rooms) < 3 {
+		return
+	}
+	a := g.random.GetInt(0, len(g.Rooms)-1)
+	b, best := -1, -1
+	for r := range g.Rooms {
+		if r == a || g.connected(a, r) {
+			continue
+		}
+		if d := g.distance(a, r); best < 0 || d < best {
+			b, best = r, d
+		}
+	}
+	if b < 0 {
+		return
+	}
+
+	// Rooms that overlap across one axis face each other across the other.
+	ra, rb := g.Rooms[a], g.Rooms[b]
+	vertical := ra.X < rb.X+rb.W && rb.X < ra.X+ra.W
+	g.digCorridor(a, b, vertical)
+}
+
+// digCorridor joins room a to room b.  If vertical is true, the rooms are above
+// and below each other, so the corridor mostly runs vertically.
+func (g *generator) digCorridor(a, b int, vertical bool) {
+	ra, rb := g.Rooms[a], g.Rooms[b]
+	g.Connections = append(g.Connections, [2]int{a, b})
+
+	if g.config.Corridors == CorridorL {
+		x1, y1 := g.randomCell(ra)
+		x2, y2 := g.randomCell(rb)
+		if g.random.GetInt(0, 1) == 0 {
+			g.hline(x1, x2, y1)
+			g.vline(x2, y1, y2)
+		} else {
+			g.vline(x1, y1, y2)
+			g.hline(x1, x2, y2)
+		}
+		return
+	}
+
+	if !vertical {
+		// Swap the axes, so the same code handles both directions.
+		ra, rb = Rect{ra.Y, ra.X, ra.H, ra.W}, Rect{rb.Y, rb.X, rb.H, rb.W}
+	}
+	if ra.Y > rb.Y {
+		ra, rb = rb, ra
+	}
+	line := func(x, y1, y2 int) {
+		if vertical {
+			g.vline(x, y1, y2)
+		} else {
+			g.hline(y1, y2, x)
+		}
+	}
+	cross := func(x1, x2, y int) {
+		if vertical {
+			g.hline(x1, x2, y)
+		} else {
+			g.vline(y, x1, x2)
+		}
+	}
+
+	y1, y2 := ra.Y+ra.H-1, rb.Y
+	if minx, maxx := max(ra.X, rb.X), min(ra.X+ra.W-1, rb.X+rb.W-1); minx <= maxx {
+		// The rooms face each other.
+		x := g.random.GetInt(minx, maxx)
+		line(x, y1, y2)
+		return
+	}
+
+	x1 := g.random.GetInt(ra.X, ra.X+ra.W-1)
+	x2 := g.random.GetInt(rb.X, rb.X+rb.W-1)
+	y := y1
+	if y1 < y2 {
+		y = g.random.GetInt(y1+1, y2)
+	}
+	line(x1, y1, y)
+	cross(x1, x2, y)
+	line(x2, y, y2)
+}
+
+func (g *generator) randomCell(r Rect) (x, y int) {
+	return g.random.GetInt(r.X, r.X+r.W-1), g.random.GetInt(r.Y, r.Y+r.H-1)
+}
+
+// dig turns a wall into corridor.
+func (d *Dungeon) dig(x, y int) {
+	if d.inBounds(x, y) && d.Tiles[y*d.Width+x] == Wall {
+		d.Tiles[y*d.Width+x] = Corridor
+	}
+}
+
+func (d *Dungeon) hline(x1, x2, y int) {
+	for x := min(x1, x2); x <= max(x1, x2); x++ {
+		d.dig(x, y)
+	}
+}
+
+func (d *Dungeon) vline(x, y1, y2 int) {
+	for y := min(y1, y2); y <= max(y1, y2); y++ {
+		d.dig(x, y)
+	}
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func max(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,107 @@
+package dungeon
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestGenerateDeterministic(t *testing.T) {
+	config := DefaultConfig()
+	config.ExtraLoops = 3
+	first := GenerateFromSeed(80, 50, config, 7)
+	again := GenerateFromSeed(80, 50, config, 7)
+	if !reflect.DeepEqual(first, again) {
+		t.Errorf("the same seed made two dungeons:\n%s\n%s", draw(first), draw(again))
+	}
+	if other := GenerateFromSeed(80, 50, config, 8); reflect.DeepEqual(first.Tiles, other.Tiles) {
+		t.Error("different seeds made the same dungeon")
+	}
+}
+
+// checkRooms fails the test unless every room is inside the map, clear of its
+// edges, made of room tiles and apart from the other rooms.
+func checkRooms(t *testing.T, d *Dungeon, config Config) {
+	t.Helper()
+	if len(d.Rooms) == 0 {
+		t.Fatalf("no rooms:\n%s", draw(d))
+	}
+	for i, room := range d.Rooms {
+		if room.W < 1 || room.H < 1 || room.X < 1 || room.Y < 1 ||
+			room.X+room.W > d.Width-1 || room.Y+room.H > d.Height-1 {
+			t.Fatalf("room %d %+v isn't inside the %dx%d map's walls", i, room, d.Width, d.Height)
+		}
+		if config.MaxRoomSize > 0 && (room.W > config.MaxRoomSize || room.H > config.MaxRoomSize) {
+			t.Errorf("room %d %+v is bigger than %d", i, room, config.MaxRoomSize)
+		}
+		for y := room.Y; y < room.Y+room.H; y++ {
+			for x := room.X; x < room.X+room.W; x++ {
+				if d.Get(x, y) != Room {
+					t.Fatalf("room %d %+v has a %d tile at %d,%d", i, room, d.Get(x, y), x, y)
+				}
+				if r := d.RoomAt(x, y); r != i {
+					t.Fatalf("room %d %+v overlaps room %d at %d,%d", i, room, r, x, y)
+				}
+			}
+		}
+	}
+}
+
+// checkRoomsJoined fails the test unless every room can be reached from the
+// first through the dungeon's connections.
+func checkRoomsJoined(t *testing.T, d *Dungeon) {
+	t.Helper()
+	seen := map[int]bool{0: true}
+	queue := []int{0}
+	for len(queue) > 0 {
+		room := queue[0]
+		queue = queue[1:]
+		for _, n := range d.Neighbours(room) {
+			if !seen[n] {
+				seen[n] = true
+				queue = append(queue, n)
+			}
+		}
+	}
+	if len(seen) != len(d.Rooms) {
+		t.Errorf("only %d of %d rooms are joined by connections %v", len(seen), len(d.Rooms), d.Connections)
+	}
+}
+
+func TestGenerateLayout(t *testing.T) {
+	configs := map[string]Config{"default": DefaultConfig()}
+
+	config := DefaultConfig()
+	config.Corridors = CorridorL
+	config.ExtraLoops = 5
+	configs["L corridors with loops"] = config
+
+	config = DefaultConfig()
+	config.Depth = 4
+	config.MaxRoomSize = 8
+	config.Padding = 0
+	configs["small rooms without padding"] = config
+
+	for name, config := range configs {
+		for seed := uint32(1); seed <= 10; seed++ {
+			for _, size := range [][2]int{{80, 50}, {40, 25}, {17, 61}} {
+				d := GenerateFromSeed(size[0], size[1], config, seed)
+				if len(d.Tiles) != size[0]*size[1] {
+					t.Fatalf("%s, seed %d: %d tiles for %dx%d", name, seed, len(d.Tiles), size[0], size[1])
+				}
+				for x := 0; x < d.Width; x++ {
+					if d.IsFloor(x, 0) || d.IsFloor(x, d.Height-1) {
+						t.Fatalf("%s, seed %d: the top or bottom edge is open:\n%s", name, seed, draw(d))
+					}
+				}
+				for y := 0; y < d.Height; y++ {
+					if d.IsFloor(0, y) || d.IsFloor(d.Width-1, y) {
+						t.Fatalf("%s, seed %d: the left or right edge is open:\n%s", name, seed, draw(d))
+					}
+				}
+				checkRooms(t, d, config)
+				checkRoomsJoined(t, d)
+				checkConnected(t, d)
+			}
+		}
+	}
+}
@@ -0,0 +1,315 @@
+package tcod
+
+/*
+ #include <stdint.h>
+ #include <string.h>
+ #include "include/libtcod.h"
+
+ // The SDL2 events handled below, laid out as in SDL2's stable ABI.  Declaring
+ // them here saves depending on the SDL2 development headers.
+ enum {
+   _SDL_QUIT = 0x100,
+   _SDL_WINDOWEVENT = 0x200,
+   _SDL_KEYDOWN = 0x300,
+   _SDL_KEYUP = 0x301,
+   _SDL_TEXTINPUT = 0x303,
+   _SDL_MOUSEMOTION = 0x400,
+   _SDL_MOUSEBUTTONDOWN = 0x401,
+   _SDL_MOUSEBUTTONUP = 0x402,
+   _SDL_MOUSEWHEEL = 0x403,
+ };
+
+ enum {
+   _SDL_WINDOWEVENT_RESIZED = 5,
+   _SDL_WINDOWEVENT_FOCUS_GAINED = 12,
+   _SDL_WINDOWEVENT_FOCUS_LOST = 13,
+ };
+
+ typedef struct {
+   uint32_t type, timestamp, windowID;
+   uint8_t event, padding1, padding2, padding3;
+   int32_t data1, data2;
+ } _SDL_WindowEvent;
+
+ typedef struct {
+   uint32_t type, timestamp, windowID;
+   uint8_t state, repeat, padding2, padding3;
+   int32_t scancode, sym;
+   uint16_t mod;
+   uint32_t unused;
+ } _SDL_KeyboardEvent;
+
+ typedef struct {
+   uint32_t type, timestamp, windowID;
+   char text[32];
+ } _SDL_TextInputEvent;
+
+ typedef struct {
+   uint32_t type, timestamp, windowID, which, state;
+   int32_t x, y, xrel, yrel;
+ } _SDL_MouseMotionEvent;
+
+ typedef struct {
+   uint32_t type, timestamp, windowID, which;
+   uint8_t button, state, clicks, padding1;
+   int32_t x, y;
+ } _SDL_MouseButtonEvent;
+
+ typedef struct {
+   uint32_t type, timestamp, windowID, which;
+   int32_t x, y;
+   uint32_t direction;
+ } _SDL_MouseWheelEvent;
+
+ typedef union {
+   uint32_t type;
+   _SDL_WindowEvent window;
+   _SDL_KeyboardEvent key;
+   _SDL_TextInputEvent text;
+   _SDL_MouseMotionEvent motion;
+   _SDL_MouseButtonEvent button;
+   _SDL_MouseWheelEvent wheel;
+   uint8_t padding[56];
+ } _SDL_Event;
+
+ extern int SDL_PollEvent(union SDL_Event *event);
+ extern int SDL_WaitEvent(union SDL_Event *event);
+
+ static uint32_t _event_type(_SDL_Event *ev) { return ev->type; }
+ static _SDL_WindowEvent *_event_window(_SDL_Event *ev) { return &ev->window; }
+ static _SDL_KeyboardEvent *_event_key(_SDL_Event *ev) { return &ev->key; }
+ static _SDL_TextInputEvent *_event_text(_SDL_Event *ev) { return &ev->text; }
+ static _SDL_MouseMotionEvent *_event_motion(_SDL_Event *ev) { return &ev->motion; }
+ static _SDL_MouseButtonEvent *_event_button(_SDL_Event *ev) { return &ev->button; }
+ static _SDL_MouseWheelEvent *_event_wheel(_SDL_Event *ev) { return &ev->wheel; }
+
+ // Converts pixel coordinates to root console tiles by letting libtcod process a
+ // mouse motion to them.
+ static void _root_pixel_to_tile(int *x, int *y) {
+   _SDL_Event ev;
+   TCOD_mouse_t mouse;
+   memset(&ev, 0, sizeof(ev));
+   memset(&mouse, 0, sizeof(mouse));
+   ev.motion.type = _SDL_MOUSEMOTION;
+   ev.motion.x = *x;
+   ev.motion.y = *y;
+   TCOD_sys_process_mouse_event((const union SDL_Event *)&ev, &mouse);
+   *x = mouse.cx;
+   *y = mouse.cy;
+ }
+*/
+import "C"
+
+import (
+	"unsafe"
+)
+
+//
+// Typed events
+//
+
+// InputEvent is one of the typed events returned by PollEvent, WaitEvent and
+// Events: KeyDown, KeyUp, TextInput, MouseMotion, MouseButton, MouseWheel,
+// WindowResized, WindowFocus or Quit.  Use a type switch to handle them.
+type InputEvent interface {
+	inputEvent()
+}
+
+// KeyDown is sent when a key is pressed, and again while it's held down if the
+// system repeats keys.
+type KeyDown struct {
+	Key
+	Repeat bool
+}
+
+// KeyUp is sent when a key is released.
+type KeyUp struct {
+	Key
+}
+
+// TextInput is sent with the UTF-8 text typed by the user, after keyboard layout
+// and input method processing.  Prefer it over KeyDown for reading text.
+type TextInput struct {
+	Text string
+}
+
+// MouseMotion is sent when the mouse moves.  X and Y are in pixels, Cx and Cy are
+// the tile under the mouse.
+type MouseMotion struct {
+	X, Y   int
+	Dx, Dy int
+	Cx, Cy int
+}
+
+// MouseButtonID identifies a mouse button.
+type MouseButtonID int
+
+const (
+	ButtonLeft   MouseButtonID = 1
+	ButtonMiddle MouseButtonID = 2
+	ButtonRight  MouseButtonID = 3
+	ButtonX1     MouseButtonID = 4
+	ButtonX2     MouseButtonID = 5
+)
+
+// MouseButton is sent when a mouse button is pressed or released.  Clicks is 1
+// for a single click, 2 for a double click, and so on.
+type MouseButton struct {
+	Button  MouseButtonID
+	Pressed bool
+	Clicks  int
+	X, Y    int
+	Cx, Cy  int
+}
+
+// MouseWheel is sent when the mouse wheel scrolls.  Positive Y scrolls up, away
+// from the user, and positive X scrolls right.
+type MouseWheel struct {
+	X, Y int
+}
+
+// WindowResized is sent when the window changes size, in pixels.
+type WindowResized struct {
+	Width, Height int
+}
+
+// WindowFocus is sent when the window gains or loses keyboard focus.
+type WindowFocus struct {
+	Focused bool
+}
+
+// Quit is sent when the user closes the window.
+type Quit struct{}
+
+func (KeyDown) inputEvent()       {}
+func (KeyUp) inputEvent()         {}
+func (TextInput) inputEvent()     {}
+func (MouseMotion) inputEvent()   {}
+func (MouseButton) inputEvent()   {}
+func (MouseWheel) inputEvent()    {}
+func (WindowResized) inputEvent() {}
+func (WindowFocus) inputEvent()   {}
+func (Quit) inputEvent()          {}
+
+// PollEvent returns the next pending event, or nil if there are none.  Mouse tile
+// coordinates are worked out by context, or by the root console if context is nil.
+//
+// Like the rest of SDL, events must be read from the thread that opened the window.
+func PollEvent(context *Context) InputEvent {
+	var ev C._SDL_Event
+	for C.SDL_PollEvent((*C.union_SDL_Event)(unsafe.Pointer(&ev))) != 0 {
+		if result := toInputEvent(context, &ev); result != nil {
+			return result
+		}
+	}
+	return nil
+}
+
+// WaitEvent blocks until an event arrives and returns it.  It returns nil if SDL
+// reports an error while waiting.
+func WaitEvent(context *Context) InputEvent {
+	var ev C._SDL_Event
+	for C.SDL_WaitEvent((*C.union_SDL_Event)(unsafe.Pointer(&ev))) != 0 {
+		if result := toInputEvent(context, &ev); result != nil {
+			return result
+		}
+	}
+	return nil
+}
+
+// Events returns the pending events on a closed, buffered channel, so a frame's
+// input can be handled with a range loop:
+//
+//	for ev := range tcod.Events(context) {
+//		switch ev := ev.(type) {
+//		case tcod.KeyDown:
+//			...
+//		}
+//	}
+func Events(context *Context) <-chan InputEvent {
+	var pending []InputEvent
+	for ev := PollEvent(context); ev != nil; ev = PollEvent(context) {
+		pending = append(pending, ev)
+	}
+
+	result := make(chan InputEvent, len(pending))
+	for _, ev := range pending {
+		result <- ev
+	}
+	close(result)
+	return result
+}
+
+// toInputEvent converts an SDL event, returning nil for events without a Go type.
+func toInputEvent(context *Context, ev *C._SDL_Event) InputEvent {
+	switch C._event_type(ev) {
+	case C._SDL_QUIT:
+		return Quit{}
+
+	case C._SDL_WINDOWEVENT:
+		window := C._event_window(ev)
+		switch window.event {
+		case C._SDL_WINDOWEVENT_RESIZED:
+			return WindowResized{int(window.data1), int(window.data2)}
+		case C._SDL_WINDOWEVENT_FOCUS_GAINED:
+			return WindowFocus{true}
+		case C._SDL_WINDOWEVENT_FOCUS_LOST:
+			return WindowFocus{false}
+		}
+
+	case C._SDL_KEYDOWN, C._SDL_KEYUP:
+		var key C.TCOD_key_t
+		C.TCOD_sys_process_key_event((*C.union_SDL_Event)(unsafe.Pointer(ev)), &key)
+		if C._event_type(ev) == C._SDL_KEYUP {
+			return KeyUp{toKey(key)}
+		}
+		return KeyDown{toKey(key), C._event_key(ev).repeat != 0}
+
+	case C._SDL_TEXTINPUT:
+		return TextInput{C.GoString(&C._event_text(ev).text[0])}
+
+	case C._SDL_MOUSEMOTION:
+		motion := C._event_motion(ev)
+		x, y := int(motion.x), int(motion.y)
+		cx, cy := pixelToTile(context, x, y)
+		return MouseMotion{x, y, int(motion.xrel), int(motion.yrel), cx, cy}
+
+	case C._SDL_MOUSEBUTTONDOWN, C._SDL_MOUSEBUTTONUP:
+		button := C._event_button(ev)
+		x, y := int(button.x), int(button.y)
+		cx, cy := pixelToTile(context, x, y)
+		return MouseButton{
+			Button:  MouseButtonID(button.button),
+			Pressed: C._event_type(ev) == C._SDL_MOUSEBUTTONDOWN,
+			Clicks:  int(button.clicks),
+			X:       x,
+			Y:       y,
+			Cx:      cx,
+			Cy:      cy,
+		}
+
+	case C._SDL_MOUSEWHEEL:
+		wheel := C._event_wheel(ev)
+		x, y := int(wheel.x), int(wheel.y)
+		if wheel.direction != 0 { // SDL_MOUSEWHEEL_FLIPPED
+			x, y = -x, -y
+		}
+		return MouseWheel{x, y}
+	}
+	return nil
+}
+
+// pixelToTile converts window pixel coordinates to tile coordinates, or -1, -1 if
+// context can't convert them yet.
+func pixelToTile(context *Context, x, y int) (int, int) {
+	if context != nil {
+		cx, cy, err := context.ScreenPixelToTileInt(x, y)
+		if err != nil {
+			return -1, -1
+		}
+		return cx, cy
+	}
+	cx, cy := C.int(x), C.int(y)
+	C._root_pixel_to_tile(&cx, &cy)
+	return int(cx), int(cy)
+}
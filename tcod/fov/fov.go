@@ -0,0 +1,230 @@
+// Package fov computes field of view in pure Go, using the same algorithms as
+// libtcod's TCOD_map_compute_fov.  It doesn't use cgo, so it can be used where
+// libtcod isn't installed, such as a game server running the simulation.
+package fov
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Algorithm selects how field of view is computed.  The values match
+// tcod.FovAlgorithm, so one can be converted to the other.
+type Algorithm int
+
+const (
+	// Basic casts rays from the viewer to every cell on the edge of the map
+	// or radius.
+	Basic Algorithm = iota
+
+	// Diamond is diamond raycasting.
+	Diamond
+
+	// Shadow is recursive shadowcasting.
+	Shadow
+
+	// Permissive0 to Permissive8 are precise permissive field of view, from
+	// the least permissive (0) to the most (8).
+	Permissive0
+	Permissive1
+	Permissive2
+	Permissive3
+	Permissive4
+	Permissive5
+	Permissive6
+	Permissive7
+	Permissive8
+
+	// Restrictive is Mingos' restrictive precise angle shadowcasting.
+	Restrictive
+)
+
+// Permissive returns the precise permissive algorithm with the given
+// permissiveness, from 0 to 8.
+func Permissive(n int) Algorithm {
+	return Permissive0 + Algorithm(n)
+}
+
+// Grid is a map the field of view is computed over.
+type Grid interface {
+	GetWidth() int
+	GetHeight() int
+
+	// IsTransparent reports whether light passes through a cell.
+	IsTransparent(x, y int) bool
+
+	// SetVisible marks a cell as seen from the viewer.
+	SetVisible(x, y int)
+}
+
+// Compute computes the field of view from x, y over grid, calling SetVisible for
+// each cell in view.  It doesn't clear cells marked visible earlier.  A
+// maxRadius of 0 means the view is unlimited.  If lightWalls is set, opaque
+// cells at the edge of the view are visible too.
+func Compute(grid Grid, x, y, maxRadius int, lightWalls bool, algo Algorithm) error {
+	m := NewMap(grid.GetWidth(), grid.GetHeight())
+	for cy := 0; cy < m.h; cy++ {
+		for cx := 0; cx < m.w; cx++ {
+			m.transparent[cy*m.w+cx] = grid.IsTransparent(cx, cy)
+		}
+	}
+	if err := m.ComputeFov(x, y, maxRadius, lightWalls, algo); err != nil {
+		return err
+	}
+	for cy := 0; cy < m.h; cy++ {
+		for cx := 0; cx < m.w; cx++ {
+			if m.visible[cy*m.w+cx] {
+				grid.SetVisible(cx, cy)
+			}
+		}
+	}
+	return nil
+}
+
+// Map is a simple Grid that stores transparency and visibility for each cell.
+type Map struct {
+	w, h        int
+	transparent []bool
+	visible     []bool
+}
+
+// NewMap creates a w x h map where every cell is opaque and not visible.
+func NewMap(w, h int) *Map {
+	return &Map{
+		w:           w,
+		h:           h,
+		transparent: make([]bool, w*h),
+		visible:     make([]bool, w*h),
+	}
+}
+
+func (m *Map) GetWidth() int {
+	return m.w
+}
+
+func (m *Map) GetHeight() int {
+	return m.h
+}
+
+func (m *Map) inBounds(x, y int) bool {
+	return x >= 0 && y >= 0 && x < m.w && y < m.h
+}
+
+func (m *Map) SetTransparent(x, y int, transparent bool) {
+	if m.inBounds(x, y) {
+		m.transparent[y*m.w+x] = transparent
+	}
+}
+
+func (m *Map) IsTransparent(x, y int) bool {
+	return m.inBounds(x, y) && m.transparent[y*m.w+x]
+}
+
+func (m *Map) SetVisible(x, y int) {
+	if m.inBounds(x, y) {
+		m.visible[y*m.w+x] = true
+	}
+}
+
+func (m *Map) IsVisible(x, y int) bool {
+	return m.inBounds(x, y) && m.visible[y*m.w+x]
+}
+
+// ClearVisible marks every cell as not visible.
+func (m *Map) ClearVisible() {
+	for i := range m.visible {
+		m.visible[i] = false
+	}
+}
+
+// ComputeFov replaces the map's visible cells with the field of view from x, y,
+// as for Compute.
+func (m *Map) ComputeFov(x, y, maxRadius int, lightWalls bool, algo Algorithm) error {
+	if !m.inBounds(x, y) {
+		return fmt.Errorf("fov: point of view %d,%d is outside the %dx%d map", x, y, m.w, m.h)
+	}
+	if maxRadius < 0 {
+		return errors.New("fov: radius can't be negative")
+	}
+
+	m.ClearVisible()
+	switch {
+	case algo == Basic:
+		computeCircularRaycasting(m, x, y, maxRadius, lightWalls)
+	case algo == Diamond:
+		computeDiamondRaycasting(m, x, y, maxRadius, lightWalls)
+	case algo == Shadow:
+		computeRecursiveShadowcasting(m, x, y, maxRadius, lightWalls)
+	case algo >= Permissive0 && algo <= Permissive8:
+		computePermissive(m, x, y, maxRadius, lightWalls, int(algo-Permissive0))
+	case algo == Restrictive:
+		computeRestrictiveShadowcasting(m, x, y, maxRadius, lightWalls)
+	default:
+		return fmt.Errorf("fov: unknown algorithm %d", algo)
+	}
+	return nil
+}
+
+// lightWalls marks the walls next to visible floor in the given quarter of the
+// view, to fill gaps the ray casting algorithms leave in walls.  dx and dy point
+// away from the viewer.
+func lightWalls(m *Map, x0, y0, x1, y1, dx, dy int) {
+	for cx := x0; cx <= x1; cx++ {
+		for cy := y0; cy <= y1; cy++ {
+			if !m.inBounds(cx, cy) || !m.visible[cy*m.w+cx] || !m.transparent[cy*m.w+cx] {
+				continue
+			}
+			x2, y2 := cx+dx, cy+dy
+			if x2 >= x0 && x2 <= x1 {
+				lightWall(m, x2, cy)
+			}
+			if y2 >= y0 && y2 <= y1 {
+				lightWall(m, cx, y2)
+			}
+			if x2 >= x0 && x2 <= x1 && y2 >= y0 && y2 <= y1 {
+				lightWall(m, x2, y2)
+			}
+		}
+	}
+}
+
+func lightWall(m *Map, x, y int) {
+	if m.inBounds(x, y) && !m.transparent[y*m.w+x] {
+		m.visible[y*m.w+x] = true
+	}
+}
+
+// lightWallsAround lights walls in all four quarters around the viewer, within
+// maxRadius.
+func lightWallsAround(m *Map, px, py, maxRadius int) {
+	xmin, ymin, xmax, ymax := 0, 0, m.w, m.h
+	if maxRadius > 0 {
+		xmin, ymin = max(0, px-maxRadius), max(0, py-maxRadius)
+		xmax, ymax = min(m.w, px+maxRadius+1), min(m.h, py+maxRadius+1)
+	}
+	lightWalls(m, xmin, ymin, px, py, -1, -1)
+	lightWalls(m, px, ymin, xmax-1, py, 1, -1)
+	lightWalls(m, xmin, py, px, ymax-1, -1, 1)
+	lightWalls(m, px, py, xmax-1, ymax-1, 1, 1)
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func max(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+func abs(a int) int {
+	if a < 0 {
+		return -a
+	}
+	return a
+}
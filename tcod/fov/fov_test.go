@@ -0,0 +1,88 @@
+package fov_test
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+	"testing"
+
+	"github.com/sbowman/tcod/tcod"
+	"github.com/sbowman/tcod/tcod/fov"
+)
+
+// randomMaps builds the same random map for both packages: a border of walls,
+// and wallRatio of the cells inside it opaque.
+func randomMaps(rnd *rand.Rand, w, h int, wallRatio float64) (*fov.Map, *tcod.Map) {
+	m := fov.NewMap(w, h)
+	cm := tcod.NewMap(w, h)
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			edge := x == 0 || y == 0 || x == w-1 || y == h-1
+			transparent := !edge && rnd.Float64() >= wallRatio
+			m.SetTransparent(x, y, transparent)
+			cm.SetProperties(x, y, transparent, transparent)
+		}
+	}
+	return m, cm
+}
+
+// diff draws the two fields of view, marking cells only the Go algorithm sees
+// with "+" and cells only libtcod sees with "-".
+func diff(m *fov.Map, cm *tcod.Map, px, py int) (string, bool) {
+	var b strings.Builder
+	same := true
+	for y := 0; y < m.GetHeight(); y++ {
+		for x := 0; x < m.GetWidth(); x++ {
+			goSees, cSees := m.IsVisible(x, y), cm.IsInFov(x, y)
+			switch {
+			case x == px && y == py:
+				b.WriteByte('@')
+			case goSees && !cSees:
+				b.WriteByte('+')
+				same = false
+			case cSees && !goSees:
+				b.WriteByte('-')
+				same = false
+			case !m.IsTransparent(x, y):
+				b.WriteByte('#')
+			case goSees:
+				b.WriteByte('.')
+			default:
+				b.WriteByte(' ')
+			}
+		}
+		b.WriteByte('\n')
+	}
+	return b.String(), same
+}
+
+func TestMatchesLibtcod(t *testing.T) {
+	const w, h = 48, 32
+	algorithms := []fov.Algorithm{fov.Basic, fov.Diamond, fov.Shadow, fov.Restrictive}
+	for n := 0; n <= 8; n++ {
+		algorithms = append(algorithms, fov.Permissive(n))
+	}
+
+	rnd := rand.New(rand.NewSource(1))
+	for trial := 0; trial < 6; trial++ {
+		m, cm := randomMaps(rnd, w, h, 0.1+0.08*float64(trial))
+		px, py := 1+rnd.Intn(w-2), 1+rnd.Intn(h-2)
+		m.SetTransparent(px, py, true)
+		cm.SetProperties(px, py, true, true)
+
+		for _, algo := range algorithms {
+			for _, radius := range []int{0, 1, 4, 9, 20} {
+				for _, lightWalls := range []bool{false, true} {
+					name := fmt.Sprintf("map %d algorithm %d radius %d lightWalls %v", trial, algo, radius, lightWalls)
+					if err := m.ComputeFov(px, py, radius, lightWalls, algo); err != nil {
+						t.Fatalf("%s: %v", name, err)
+					}
+					cm.ComputeFov(px, py, radius, lightWalls, tcod.FovAlgorithm(algo))
+					if picture, same := diff(m, cm, px, py); !same {
+						t.Errorf("%s differs from libtcod (+ only in Go, - only in libtcod):\n%s", name, picture)
+					}
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,200 @@
+package fov
+
+//
+// Precise permissive field of view (Permissive0 to Permissive8)
+//
+
+// Cells are divided into stepSize x stepSize units, so the permissiveness can
+// shrink the area of the viewer's cell that sight lines start from.
+const stepSize = 16
+
+// permissiveLine is a sight line from xi, yi to xf, yf.
+type permissiveLine struct {
+	xi, yi, xf, yf int
+}
+
+func (l *permissiveLine) relativeSlope(x, y int) int {
+	return (l.yf-l.yi)*(l.xf-x) - (l.xf-l.xi)*(l.yf-y)
+}
+
+func (l *permissiveLine) below(x, y int) bool {
+	return l.relativeSlope(x, y) > 0
+}
+
+func (l *permissiveLine) belowOrColinear(x, y int) bool {
+	return l.relativeSlope(x, y) >= 0
+}
+
+func (l *permissiveLine) above(x, y int) bool {
+	return l.relativeSlope(x, y) < 0
+}
+
+func (l *permissiveLine) aboveOrColinear(x, y int) bool {
+	return l.relativeSlope(x, y) <= 0
+}
+
+func (l *permissiveLine) colinear(x, y int) bool {
+	return l.relativeSlope(x, y) == 0
+}
+
+func (l *permissiveLine) lineColinear(l2 *permissiveLine) bool {
+	return l.colinear(l2.xi, l2.yi) && l.colinear(l2.xf, l2.yf)
+}
+
+// viewBump is a corner a sight line has been bent around.
+type viewBump struct {
+	x, y   int
+	parent *viewBump
+}
+
+// view is a wedge of the quadrant that's still visible, between a shallow and a
+// steep line.
+type view struct {
+	shallowLine, steepLine permissiveLine
+	shallowBump, steepBump *viewBump
+}
+
+type permissive struct {
+	m             *Map
+	walls         bool
+	offset, limit int
+	active        []*view
+	current       int
+}
+
+func computePermissive(m *Map, px, py, maxRadius int, walls bool, permissiveness int) {
+	p := &permissive{
+		m:      m,
+		walls:  walls,
+		offset: 8 - permissiveness,
+		limit:  8 + permissiveness,
+	}
+	m.visible[py*m.w+px] = true
+
+	minx, maxx := px, m.w-px-1
+	miny, maxy := py, m.h-py-1
+	if maxRadius > 0 {
+		minx, maxx = min(minx, maxRadius), min(maxx, maxRadius)
+		miny, maxy = min(miny, maxRadius), min(maxy, maxRadius)
+	}
+
+	p.checkQuadrant(px, py, 1, 1, maxx, maxy)
+	p.checkQuadrant(px, py, 1, -1, maxx, miny)
+	p.checkQuadrant(px, py, -1, -1, minx, miny)
+	p.checkQuadrant(px, py, -1, 1, minx, maxy)
+}
+
+// checkQuadrant visits the cells of a quadrant diagonal by diagonal, moving
+// outwards from the viewer.
+func (p *permissive) checkQuadrant(startX, startY, dx, dy, extentX, extentY int) {
+	p.active = append(p.active[:0], &view{
+		shallowLine: permissiveLine{p.offset, p.limit, extentX * stepSize, 0},
+		steepLine:   permissiveLine{p.limit, p.offset, 0, extentY * stepSize},
+	})
+
+	maxI := extentX + extentY
+	for i := 1; i != maxI+1 && len(p.active) > 0; i++ {
+		startJ, maxJ := max(i-extentX, 0), min(i, extentY)
+		p.current = 0
+		for j := startJ; j != maxJ+1 && p.current < len(p.active); j++ {
+			p.visit(startX, startY, (i-j)*stepSize, j*stepSize, dx, dy)
+		}
+	}
+}
+
+func (p *permissive) visit(startX, startY, x, y, dx, dy int) {
+	tlx, tly := x, y+stepSize
+	brx, bry := x+stepSize, y
+
+	// Skip the views entirely below this cell.
+	var v *view
+	for p.current < len(p.active) {
+		v = p.active[p.current]
+		if !v.steepLine.belowOrColinear(brx, bry) {
+			break
+		}
+		p.current++
+	}
+	if p.current == len(p.active) || v.shallowLine.aboveOrColinear(tlx, tly) {
+		return // the cell is between views, or above all of them
+	}
+
+	cx, cy := startX+x/stepSize*dx, startY+y/stepSize*dy
+	offset := cy*p.m.w + cx
+	if p.walls || p.m.transparent[offset] {
+		p.m.visible[offset] = true
+	}
+	if p.m.transparent[offset] {
+		return
+	}
+
+	switch {
+	case v.shallowLine.above(brx, bry) && v.steepLine.below(tlx, tly):
+		// The cell blocks the whole view.
+		p.remove(p.current)
+	case v.shallowLine.above(brx, bry):
+		// The cell cuts into the shallow line, which is raised.
+		addShallowBump(tlx, tly, v)
+		p.checkView(p.current)
+	case v.steepLine.below(tlx, tly):
+		// The cell cuts into the steep line, which is lowered.
+		addSteepBump(brx, bry, v)
+		p.checkView(p.current)
+	default:
+		// The cell is in the middle of the view, which is split in two.
+		shallower, steeper := p.current, p.current+1
+		copied := *v
+		p.insert(shallower, &copied)
+		addSteepBump(brx, bry, p.active[shallower])
+		if !p.checkView(shallower) {
+			steeper--
+		}
+		addShallowBump(tlx, tly, p.active[steeper])
+		p.checkView(steeper)
+		if p.current > len(p.active) {
+			p.current = len(p.active)
+		}
+	}
+}
+
+func addShallowBump(x, y int, v *view) {
+	v.shallowLine.xf, v.shallowLine.yf = x, y
+	v.shallowBump = &viewBump{x, y, v.shallowBump}
+	for bump := v.steepBump; bump != nil; bump = bump.parent {
+		if v.shallowLine.above(bump.x, bump.y) {
+			v.shallowLine.xi, v.shallowLine.yi = bump.x, bump.y
+		}
+	}
+}
+
+func addSteepBump(x, y int, v *view) {
+	v.steepLine.xf, v.steepLine.yf = x, y
+	v.steepBump = &viewBump{x, y, v.steepBump}
+	for bump := v.shallowBump; bump != nil; bump = bump.parent {
+		if v.steepLine.below(bump.x, bump.y) {
+			v.steepLine.xi, v.steepLine.yi = bump.x, bump.y
+		}
+	}
+}
+
+// checkView removes the view at i if it has narrowed to a line through the
+// viewer's cell, returning false if it was removed.
+func (p *permissive) checkView(i int) bool {
+	v := p.active[i]
+	if v.shallowLine.lineColinear(&v.steepLine) &&
+		(v.shallowLine.colinear(p.offset, p.limit) || v.shallowLine.colinear(p.limit, p.offset)) {
+		p.remove(i)
+		return false
+	}
+	return true
+}
+
+func (p *permissive) insert(i int, v *view) {
+	p.active = append(p.active, nil)
+	copy(p.active[i+1:], p.active[i:])
+	p.active[i] = v
+}
+
+func (p *permissive) remove(i int) {
+	p.active = append(p.active[:i], p.active[i+1:]...)
+}
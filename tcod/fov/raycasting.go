@@ -0,0 +1,296 @@
+package fov
+
+//
+// Circular raycasting (Basic)
+//
+
+func computeCircularRaycasting(m *Map, px, py, maxRadius int, walls bool) {
+	xmin, ymin, xmax, ymax := 0, 0, m.w, m.h
+	r2 := maxRadius * maxRadius
+	if maxRadius > 0 {
+		xmin, ymin = max(0, px-maxRadius), max(0, py-maxRadius)
+		xmax, ymax = min(m.w, px+maxRadius+1), min(m.h, py+maxRadius+1)
+	}
+
+	for xo := xmin; xo < xmax; xo++ {
+		castRay(m, px, py, xo, ymin, r2, walls)
+	}
+	for yo := ymin + 1; yo < ymax; yo++ {
+		castRay(m, px, py, xmax-1, yo, r2, walls)
+	}
+	for xo := xmax - 2; xo >= 0; xo-- {
+		castRay(m, px, py, xo, ymax-1, r2, walls)
+	}
+	for yo := ymax - 2; yo > 0; yo-- {
+		castRay(m, px, py, xmin, yo, r2, walls)
+	}
+
+	if walls {
+		lightWallsAround(m, px, py, maxRadius)
+	}
+}
+
+// castRay lights the cells along a line from the viewer to xd, yd, up to and
+// including the first wall.
+func castRay(m *Map, xo, yo, xd, yd, r2 int, walls bool) {
+	var line bresenham
+	line.init(xo, yo, xd, yd)
+
+	curx, cury := xo, yo
+	in, blocked := false, false
+	if m.inBounds(curx, cury) {
+		in = true
+		m.visible[cury*m.w+curx] = true
+	}
+
+	for end := false; !end; {
+		end = line.step(&curx, &cury)
+		if r2 > 0 {
+			if (curx-xo)*(curx-xo)+(cury-yo)*(cury-yo) > r2 {
+				return
+			}
+		}
+		if !m.inBounds(curx, cury) {
+			if in {
+				return // the ray left the map
+			}
+			continue
+		}
+
+		in = true
+		offset := cury*m.w + curx
+		if !blocked && !m.transparent[offset] {
+			blocked = true
+		} else if blocked {
+			return // past the wall
+		}
+		if walls || !blocked {
+			m.visible[offset] = true
+		}
+	}
+}
+
+// bresenham steps along a line the same way as libtcod's TCOD_line functions.
+type bresenham struct {
+	stepx, stepy   int
+	e              int
+	deltax, deltay int
+	origx, origy   int
+	destx, desty   int
+}
+
+func (b *bresenham) init(xFrom, yFrom, xTo, yTo int) {
+	b.origx, b.origy = xFrom, yFrom
+	b.destx, b.desty = xTo, yTo
+	b.deltax, b.deltay = xTo-xFrom, yTo-yFrom
+	b.stepx, b.stepy = sign(b.deltax), sign(b.deltay)
+	if b.stepx*b.deltax > b.stepy*b.deltay {
+		b.e = b.stepx * b.deltax
+	} else {
+		b.e = b.stepy * b.deltay
+	}
+	b.deltax *= 2
+	b.deltay *= 2
+}
+
+// step moves to the next cell of the line, returning true once the end of the
+// line has been reached, without moving.
+func (b *bresenham) step(x, y *int) bool {
+	if b.stepx*b.deltax > b.stepy*b.deltay {
+		if b.origx == b.destx {
+			return true
+		}
+		b.origx += b.stepx
+		b.e -= b.stepy * b.deltay
+		if b.e < 0 {
+			b.origy += b.stepy
+			b.e += b.stepx * b.deltax
+		}
+	} else {
+		if b.origy == b.desty {
+			return true
+		}
+		b.origy += b.stepy
+		b.e -= b.stepx * b.deltax
+		if b.e < 0 {
+			b.origx += b.stepx
+			b.e += b.stepy * b.deltay
+		}
+	}
+	*x, *y = b.origx, b.origy
+	return false
+}
+
+func sign(a int) int {
+	switch {
+	case a > 0:
+		return 1
+	case a < 0:
+		return -1
+	}
+	return 0
+}
+
+//
+// Diamond raycasting
+//
+
+// diamondRay is the state of a ray reaching one cell, relative to the viewer.
+type diamondRay struct {
+	xloc, yloc     int         // position
+	xob, yob       int         // obscurity vector
+	xerr, yerr     int         // bresenham error
+	xinput, yinput *diamondRay // the rays feeding this one
+	added          bool        // already in the perimeter
+	ignore         bool        // not visible, so not worth processing
+}
+
+func (r *diamondRay) obscure() bool {
+	return (r.xerr > 0 && r.xerr <= r.xob) || (r.yerr > 0 && r.yerr <= r.yob)
+}
+
+type diamondRaycaster struct {
+	m            *Map
+	origx, origy int
+	rays         []diamondRay
+	perim        []*diamondRay
+}
+
+func computeDiamondRaycasting(m *Map, px, py, maxRadius int, walls bool) {
+	d := &diamondRaycaster{
+		m:     m,
+		origx: px,
+		origy: py,
+		rays:  make([]diamondRay, m.w*m.h),
+		perim: make([]*diamondRay, 0, m.w*m.h),
+	}
+	r2 := maxRadius * maxRadius
+
+	d.expandPerimeterFrom(d.ray(0, 0))
+	for i := 0; i < len(d.perim); i++ {
+		ray := d.perim[i]
+		distance := 0
+		if r2 > 0 {
+			distance = ray.xloc*ray.xloc + ray.yloc*ray.yloc
+		}
+		if distance <= r2 {
+			d.mergeInput(ray)
+			if !ray.ignore {
+				d.expandPerimeterFrom(ray)
+			}
+		} else {
+			ray.ignore = true
+		}
+	}
+
+	for i := range d.rays {
+		ray := &d.rays[i]
+		m.visible[i] = ray.added && !ray.ignore && !ray.obscure()
+	}
+	m.visible[py*m.w+px] = true
+
+	if walls {
+		lightWallsAround(m, px, py, maxRadius)
+	}
+}
+
+// ray returns the ray for the cell at x, y relative to the viewer, or nil if
+// it's off the map.
+func (d *diamondRaycaster) ray(x, y int) *diamondRay {
+	if !d.m.inBounds(x+d.origx, y+d.origy) {
+		return nil
+	}
+	r := &d.rays[x+d.origx+(y+d.origy)*d.m.w]
+	r.xloc, r.yloc = x, y
+	return r
+}
+
+func (d *diamondRaycaster) processRay(newRay, inputRay *diamondRay) {
+	if newRay == nil {
+		return
+	}
+	if newRay.yloc == inputRay.yloc {
+		newRay.xinput = inputRay
+	} else {
+		newRay.yinput = inputRay
+	}
+	if !newRay.added {
+		d.perim = append(d.perim, newRay)
+		newRay.added = true
+	}
+}
+
+func (d *diamondRaycaster) expandPerimeterFrom(r *diamondRay) {
+	if r.xloc >= 0 {
+		d.processRay(d.ray(r.xloc+1, r.yloc), r)
+	}
+	if r.xloc <= 0 {
+		d.processRay(d.ray(r.xloc-1, r.yloc), r)
+	}
+	if r.yloc >= 0 {
+		d.processRay(d.ray(r.xloc, r.yloc+1), r)
+	}
+	if r.yloc <= 0 {
+		d.processRay(d.ray(r.xloc, r.yloc-1), r)
+	}
+}
+
+func processXInput(newRay, xinput *diamondRay) {
+	if xinput.xob == 0 && xinput.yob == 0 {
+		return
+	}
+	if xinput.xerr > 0 && newRay.xob == 0 {
+		newRay.xerr = xinput.xerr - xinput.yob
+		newRay.yerr = xinput.yerr + xinput.yob
+		newRay.xob, newRay.yob = xinput.xob, xinput.yob
+	}
+	if xinput.yerr <= 0 && xinput.yob > 0 && xinput.xerr > 0 {
+		newRay.yerr = xinput.yerr + xinput.yob
+		newRay.xerr = xinput.xerr - xinput.yob
+		newRay.xob, newRay.yob = xinput.xob, xinput.yob
+	}
+}
+
+func processYInput(newRay, yinput *diamondRay) {
+	if yinput.xob == 0 && yinput.yob == 0 {
+		return
+	}
+	if yinput.yerr > 0 && newRay.yob == 0 {
+		newRay.yerr = yinput.yerr - yinput.xob
+		newRay.xerr = yinput.xerr + yinput.xob
+		newRay.xob, newRay.yob = yinput.xob, yinput.yob
+	}
+	if yinput.xerr <= 0 && yinput.xob > 0 && yinput.yerr > 0 {
+		newRay.yerr = yinput.yerr - yinput.xob
+		newRay.xerr = yinput.xerr + yinput.xob
+		newRay.xob, newRay.yob = yinput.xob, yinput.yob
+	}
+}
+
+func (d *diamondRaycaster) mergeInput(r *diamondRay) {
+	xi, yi := r.xinput, r.yinput
+	if xi != nil {
+		processXInput(r, xi)
+	}
+	if yi != nil {
+		processYInput(r, yi)
+	}
+
+	switch {
+	case xi == nil:
+		if yi.obscure() {
+			r.ignore = true
+		}
+	case yi == nil:
+		if xi.obscure() {
+			r.ignore = true
+		}
+	case xi.obscure() && yi.obscure():
+		r.ignore = true
+	}
+
+	if !r.ignore && !d.m.transparent[r.xloc+d.origx+(r.yloc+d.origy)*d.m.w] {
+		r.xerr, r.xob = abs(r.xloc), abs(r.xloc)
+		r.yerr, r.yob = abs(r.yloc), abs(r.yloc)
+	}
+}
@@ -0,0 +1,217 @@
+package fov
+
+import (
+	"math"
+)
+
+//
+// Recursive shadowcasting (Shadow)
+//
+
+// octantTransforms map each octant's row and column to map coordinates.
+var octantTransforms = [4][8]int{
+	{1, 0, 0, -1, -1, 0, 0, 1},
+	{0, 1, -1, 0, 0, -1, 1, 0},
+	{0, 1, 1, 0, 0, -1, -1, 0},
+	{1, 0, 0, 1, -1, 0, 0, -1},
+}
+
+func computeRecursiveShadowcasting(m *Map, px, py, maxRadius int, walls bool) {
+	if maxRadius == 0 {
+		rx, ry := max(m.w-px, px), max(m.h-py, py)
+		maxRadius = int(math.Sqrt(float64(rx*rx+ry*ry))) + 1
+	}
+	r2 := maxRadius * maxRadius
+
+	for oct := 0; oct < 8; oct++ {
+		castLight(m, px, py, 1, 1.0, 0.0, maxRadius, r2,
+			octantTransforms[0][oct], octantTransforms[1][oct],
+			octantTransforms[2][oct], octantTransforms[3][oct], walls)
+	}
+	m.visible[py*m.w+px] = true
+}
+
+// castLight scans an octant row by row from row outwards, lighting the cells
+// between the start and end slopes, and recursing past each wall.
+func castLight(m *Map, cx, cy, row int, start, end float32, radius, r2, xx, xy, yx, yy int, walls bool) {
+	if start < end {
+		return
+	}
+
+	var newStart float32
+	for j := row; j < radius+1; j++ {
+		dx, dy := -j-1, -j
+		blocked := false
+		for dx <= 0 {
+			dx++
+			x, y := cx+dx*xx+dy*xy, cy+dx*yx+dy*yy
+			if !m.inBounds(x, y) {
+				continue
+			}
+
+			offset := y*m.w + x
+			lSlope := (float32(dx) - 0.5) / (float32(dy) + 0.5)
+			rSlope := (float32(dx) + 0.5) / (float32(dy) - 0.5)
+			if start < rSlope {
+				continue
+			} else if end > lSlope {
+				break
+			}
+			if dx*dx+dy*dy <= r2 && (walls || m.transparent[offset]) {
+				m.visible[offset] = true
+			}
+
+			if blocked {
+				if !m.transparent[offset] {
+					newStart = rSlope
+					continue
+				}
+				blocked = false
+				start = newStart
+			} else if !m.transparent[offset] && j < radius {
+				blocked = true
+				castLight(m, cx, cy, j+1, start, lSlope, radius, r2, xx, xy, yx, yy, walls)
+				newStart = rSlope
+			}
+		}
+		if blocked {
+			break
+		}
+	}
+}
+
+//
+// Restrictive precise angle shadowcasting (Restrictive)
+//
+
+func computeRestrictiveShadowcasting(m *Map, px, py, maxRadius int, walls bool) {
+	m.visible[py*m.w+px] = true
+
+	var r restrictive
+	r.computeQuadrant(m, px, py, maxRadius, walls, 1, 1)
+	r.computeQuadrant(m, px, py, maxRadius, walls, 1, -1)
+	r.computeQuadrant(m, px, py, maxRadius, walls, -1, 1)
+	r.computeQuadrant(m, px, py, maxRadius, walls, -1, -1)
+}
+
+// restrictive holds the angles of the obstacles found so far in an octant.
+type restrictive struct {
+	startAngle, endAngle []float64
+}
+
+// computeQuadrant computes the two octants of a quadrant, the one bordering the
+// vertical axis and the one bordering the horizontal axis.
+func (r *restrictive) computeQuadrant(m *Map, px, py, maxRadius int, walls bool, dx, dy int) {
+	// The vertical edge scans rows, the horizontal edge scans columns.
+	r.computeOctant(m, px, py, maxRadius, walls, dx, dy, false)
+	r.computeOctant(m, px, py, maxRadius, walls, dx, dy, true)
+}
+
+func (r *restrictive) computeOctant(m *Map, px, py, maxRadius int, walls bool, dx, dy int, horizontal bool) {
+	r.startAngle, r.endAngle = r.startAngle[:0], r.endAngle[:0]
+
+	// In map coordinates, a line is a row in the vertical octant and a column in
+	// the horizontal one, and cells step along the line by (sx, sy).  lineX and
+	// lineY step from one line to the next.
+	lineX, lineY, sx, sy := 0, dy, dx, 0
+	if horizontal {
+		lineX, lineY, sx, sy = dx, 0, 0, dy
+	}
+
+	iteration := 1
+	done := false
+	totalObstacles := 0
+	obstaclesInLastLine := 0
+	minAngle := 0.0
+
+	lx, ly := px+lineX, py+lineY
+	if !m.inBounds(lx, ly) {
+		done = true
+	}
+	for !done {
+		slopesPerCell := 1.0 / float64(iteration)
+		halfSlopes := slopesPerCell * 0.5
+		processedCell := int((minAngle + halfSlopes) / slopesPerCell)
+
+		done = true
+		for {
+			x, y := lx+processedCell*sx, ly+processedCell*sy
+			if horizontal {
+				if y < max(0, py-iteration) || y > min(m.h-1, py+iteration) {
+					break
+				}
+			} else if x < max(0, px-iteration) || x > min(m.w-1, px+iteration) {
+				break
+			}
+			c := y*m.w + x
+
+			visible := true
+			extended := false
+			centreSlope := float64(processedCell) * slopesPerCell
+			startSlope := centreSlope - halfSlopes
+			endSlope := centreSlope + halfSlopes
+
+			if obstaclesInLastLine > 0 && !m.visible[c] {
+				// The cells behind this one, on the previous line.
+				bx, by := x-lineX, y-lineY
+				b := by*m.w + bx
+				ox, oy := bx-sx, by-sy
+				if (!m.visible[b] || !m.transparent[b]) &&
+					m.inBounds(ox, oy) && (!m.visible[oy*m.w+ox] || !m.transparent[oy*m.w+ox]) {
+					visible = false
+				} else {
+					for idx := 0; visible && idx < obstaclesInLastLine; idx++ {
+						if r.startAngle[idx] > endSlope || r.endAngle[idx] < startSlope {
+							continue
+						}
+						if m.transparent[c] {
+							if centreSlope > r.startAngle[idx] && centreSlope < r.endAngle[idx] {
+								visible = false
+							}
+						} else if startSlope >= r.startAngle[idx] && endSlope <= r.endAngle[idx] {
+							visible = false
+						} else {
+							r.startAngle[idx] = math.Min(r.startAngle[idx], startSlope)
+							r.endAngle[idx] = math.Max(r.endAngle[idx], endSlope)
+							extended = true
+						}
+					}
+				}
+			}
+
+			if visible {
+				done = false
+				m.visible[c] = true
+				// An opaque cell blocks the slopes behind it.
+				if !m.transparent[c] {
+					if minAngle >= startSlope {
+						minAngle = endSlope
+						// Nothing more in the line needs checking if the
+						// minimum angle covers its last cell.
+						if processedCell == iteration {
+							done = true
+						}
+					} else if !extended {
+						r.startAngle = append(r.startAngle[:totalObstacles], startSlope)
+						r.endAngle = append(r.endAngle[:totalObstacles], endSlope)
+						totalObstacles++
+					}
+					if !walls {
+						m.visible[c] = false
+					}
+				}
+			}
+			processedCell++
+		}
+
+		if iteration == maxRadius {
+			done = true
+		}
+		iteration++
+		obstaclesInLastLine = totalObstacles
+		lx, ly = lx+lineX, ly+lineY
+		if !m.inBounds(lx, ly) {
+			done = true
+		}
+	}
+}
@@ -0,0 +1,241 @@
+package tcod
+
+/*
+ #include "include/libtcod.h"
+*/
+import "C"
+
+import (
+	"unsafe"
+
+	"github.com/sbowman/tcod/tcod/headless"
+)
+
+//
+// Headless console
+//
+
+// HeadlessConsole is an in-memory console implemented entirely in Go.  It follows
+// the same drawing, printing and blending rules as Console, but never calls into
+// libtcod, so screen layouts can be rendered and compared in tests without a
+// window.
+//
+// HeadlessConsole adapts a headless.Console to IConsole.  Code that doesn't need
+// the rest of this package can use the headless package directly, without cgo.
+//
+// GetData returns nil, so a headless console can't be handed to libtcod functions
+// that take a C console, such as Text.Render or Image.Blit.
+type HeadlessConsole struct {
+	console *headless.Console
+}
+
+// The headless package can't include libtcod's headers, so it keeps its own
+// copies of the constants and tile layout.  These fail to compile if they drift
+// apart.
+const (
+	_ = uint(BkgndDefault-headless.BkgndDefault) + uint(headless.BkgndDefault-BkgndDefault)
+	_ = uint(Center-headless.Center) + uint(headless.Center-Center)
+	_ = uint(COLCTRL_STOP-headless.COLCTRL_STOP) + uint(headless.COLCTRL_STOP-COLCTRL_STOP)
+	_ = uint(COLCTRL_NUMBER-headless.COLCTRL_NUMBER) + uint(headless.COLCTRL_NUMBER-COLCTRL_NUMBER)
+	_ = uint(CHAR_HLINE-headless.CHAR_HLINE) + uint(headless.CHAR_HLINE-CHAR_HLINE)
+	_ = uint(CHAR_VLINE-headless.CHAR_VLINE) + uint(headless.CHAR_VLINE-CHAR_VLINE)
+	_ = uint(CHAR_NE-headless.CHAR_NE) + uint(headless.CHAR_NE-CHAR_NE)
+	_ = uint(CHAR_NW-headless.CHAR_NW) + uint(headless.CHAR_NW-CHAR_NW)
+	_ = uint(CHAR_SE-headless.CHAR_SE) + uint(headless.CHAR_SE-CHAR_SE)
+	_ = uint(CHAR_SW-headless.CHAR_SW) + uint(headless.CHAR_SW-CHAR_SW)
+)
+
+var _ [unsafe.Sizeof(Tile{}) - unsafe.Sizeof(headless.Tile{})]byte
+var _ [unsafe.Sizeof(headless.Tile{}) - unsafe.Sizeof(Tile{})]byte
+
+// NewHeadlessConsole creates a w x h in-memory console, cleared to white on black.
+func NewHeadlessConsole(w, h int) *HeadlessConsole {
+	return &HeadlessConsole{headless.NewConsole(w, h)}
+}
+
+// Headless returns the headless.Console behind the console.
+func (console *HeadlessConsole) Headless() *headless.Console {
+	return console.console
+}
+
+func (console *HeadlessConsole) GetData() C.TCOD_console_t {
+	return nil
+}
+
+func (console *HeadlessConsole) GetDefaultBackground() Color {
+	return Color(console.console.GetDefaultBackground())
+}
+
+func (console *HeadlessConsole) GetDefaultForeground() Color {
+	return Color(console.console.GetDefaultForeground())
+}
+
+func (console *HeadlessConsole) SetDefaultForeground(color Color) {
+	console.console.SetDefaultForeground(headless.Color(color))
+}
+
+func (console *HeadlessConsole) SetDefaultBackground(color Color) {
+	console.console.SetDefaultBackground(headless.Color(color))
+}
+
+func (console *HeadlessConsole) Clear() {
+	console.console.Clear()
+}
+
+func (console *HeadlessConsole) GetCharBackground(x, y int) Color {
+	return Color(console.console.GetCharBackground(x, y))
+}
+
+func (console *HeadlessConsole) GetCharForeground(x, y int) Color {
+	return Color(console.console.GetCharForeground(x, y))
+}
+
+func (console *HeadlessConsole) SetCharBackground(x, y int, color Color, flag BkgndFlag) {
+	console.console.SetCharBackground(x, y, headless.Color(color), headless.BkgndFlag(flag))
+}
+
+func (console *HeadlessConsole) SetCharForeground(x, y int, color Color) {
+	console.console.SetCharForeground(x, y, headless.Color(color))
+}
+
+func (console *HeadlessConsole) SetChar(x, y int, c rune) {
+	console.console.SetChar(x, y, c)
+}
+
+func (console *HeadlessConsole) PutChar(x, y int, c rune, flag BkgndFlag) {
+	console.console.PutChar(x, y, c, headless.BkgndFlag(flag))
+}
+
+func (console *HeadlessConsole) PutCharEx(x, y int, c rune, fore, back Color) {
+	console.console.PutCharEx(x, y, c, headless.Color(fore), headless.Color(back))
+}
+
+func (console *HeadlessConsole) Print(x, y int, fmts string, v ...interface{}) {
+	console.console.Print(x, y, fmts, v...)
+}
+
+func (console *HeadlessConsole) PrintEx(x, y int, flag BkgndFlag, alignment Alignment, fmts string, v ...interface{}) {
+	console.console.PrintEx(x, y, headless.BkgndFlag(flag), headless.Alignment(alignment), fmts, v...)
+}
+
+func (console *HeadlessConsole) PrintRect(x, y, w, h int, fmts string, v ...interface{}) int {
+	return console.console.PrintRect(x, y, w, h, fmts, v...)
+}
+
+func (console *HeadlessConsole) PrintRectEx(x, y, w, h int, flag BkgndFlag, alignment Alignment, fmts string, v ...interface{}) int {
+	return console.console.PrintRectEx(x, y, w, h, headless.BkgndFlag(flag), headless.Alignment(alignment), fmts, v...)
+}
+
+func (console *HeadlessConsole) HeightRect(x, y, w, h int, fmts string, v ...interface{}) int {
+	return console.console.HeightRect(x, y, w, h, fmts, v...)
+}
+
+func (console *HeadlessConsole) SetBackgroundFlag(flag BkgndFlag) {
+	console.console.SetBackgroundFlag(headless.BkgndFlag(flag))
+}
+
+func (console *HeadlessConsole) GetBackgroundFlag() BkgndFlag {
+	return BkgndFlag(console.console.GetBackgroundFlag())
+}
+
+func (console *HeadlessConsole) SetAlignment(alignment Alignment) {
+	console.console.SetAlignment(headless.Alignment(alignment))
+}
+
+func (console *HeadlessConsole) GetAlignment() Alignment {
+	return Alignment(console.console.GetAlignment())
+}
+
+func (console *HeadlessConsole) Rect(x, y, w, h int, clear bool, flag BkgndFlag) {
+	console.console.Rect(x, y, w, h, clear, headless.BkgndFlag(flag))
+}
+
+func (console *HeadlessConsole) Hline(x, y, l int, flag BkgndFlag) {
+	console.console.Hline(x, y, l, headless.BkgndFlag(flag))
+}
+
+func (console *HeadlessConsole) Vline(x, y, l int, flag BkgndFlag) {
+	console.console.Vline(x, y, l, headless.BkgndFlag(flag))
+}
+
+func (console *HeadlessConsole) PrintFrame(x, y, w, h int, empty bool, flag BkgndFlag, fmts string, v ...interface{}) {
+	console.console.PrintFrame(x, y, w, h, empty, headless.BkgndFlag(flag), fmts, v...)
+}
+
+func (console *HeadlessConsole) GetChar(x, y int) rune {
+	return console.console.GetChar(x, y)
+}
+
+func (console *HeadlessConsole) GetWidth() int {
+	return console.console.GetWidth()
+}
+
+func (console *HeadlessConsole) GetHeight() int {
+	return console.console.GetHeight()
+}
+
+func (console *HeadlessConsole) SetKeyColor(color Color) {
+	console.console.SetKeyColor(headless.Color(color))
+}
+
+// SetColorControl sets the colors used by a color control code when printing to
+// this console.  Unlike RootConsole.SetColorControl, the colors only apply to this
+// console.
+func (console *HeadlessConsole) SetColorControl(ctrl ColCtrl, fore, back Color) {
+	console.console.SetColorControl(headless.ColCtrl(ctrl), headless.Color(fore), headless.Color(back))
+}
+
+func (console *HeadlessConsole) Blit(xSrc, ySrc, wSrc, hSrc int, dst IConsole, xDst, yDst int, foregroundAlpha, backgroundAlpha float32) {
+	console.console.Blit(xSrc, ySrc, wSrc, hSrc, toGrid(dst), xDst, yDst, foregroundAlpha, backgroundAlpha)
+}
+
+// String returns the glyphs on the console, one line per row, which is handy for
+// comparing screen layouts against golden files.
+func (console *HeadlessConsole) String() string {
+	return console.console.String()
+}
+
+// grid adapts an IConsole to the headless.Grid that headless.Blit draws with.
+type grid struct {
+	console IConsole
+}
+
+// toGrid returns the headless.Grid for console, unwrapping headless consoles.
+func toGrid(console IConsole) headless.Grid {
+	if c, ok := console.(*HeadlessConsole); ok {
+		return c.console
+	}
+	return grid{console}
+}
+
+func (g grid) GetWidth() int {
+	return g.console.GetWidth()
+}
+
+func (g grid) GetHeight() int {
+	return g.console.GetHeight()
+}
+
+func (g grid) GetChar(x, y int) rune {
+	return g.console.GetChar(x, y)
+}
+
+func (g grid) GetCharForeground(x, y int) headless.Color {
+	return headless.Color(g.console.GetCharForeground(x, y))
+}
+
+func (g grid) GetCharBackground(x, y int) headless.Color {
+	return headless.Color(g.console.GetCharBackground(x, y))
+}
+
+func (g grid) PutCharEx(x, y int, c rune, fore, back headless.Color) {
+	g.console.PutCharEx(x, y, c, Color(fore), Color(back))
+}
+
+// headlessTiles views headless tiles as Tiles, which have the same layout.
+func headlessTiles(tiles []headless.Tile) []Tile {
+	if len(tiles) == 0 {
+		return nil
+	}
+	return (*[1 << 28]Tile)(unsafe.Pointer(&tiles[0]))[:len(tiles):len(tiles)]
+}
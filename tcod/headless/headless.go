@@ -0,0 +1,758 @@
+// Package headless is an in-memory console implemented entirely in Go.  It
+// follows the same drawing, printing and blending rules as libtcod's consoles,
+// but doesn't use cgo, so screen layouts can be rendered and compared in tests
+// without libtcod or a window.
+//
+// The tcod package wraps a Console as tcod.HeadlessConsole, which can be used
+// anywhere a tcod.IConsole is expected.
+package headless
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+//
+// Types and constants
+//
+
+// Color is an RGB color.  It converts directly to and from tcod.Color.
+type Color struct {
+	R uint8
+	G uint8
+	B uint8
+}
+
+var (
+	white = Color{255, 255, 255}
+	black = Color{}
+)
+
+// BkgndFlag controls how a new background color is combined with the old one.
+// The values match libtcod's TCOD_bkgnd_flag_t.
+type BkgndFlag uint32
+
+const (
+	BkgndNone BkgndFlag = iota
+	BkgndSet
+	BkgndMultiply
+	BkgndLighten
+	BkgndDarken
+	BkgndScreen
+	BkgndColorDodge
+	BkgndColorBurn
+	BkgndAdd
+	BkgndAdda
+	BkgndBurn
+	BkgndOverlay
+	BkgndAlph
+	BkgndDefault
+)
+
+// BkgndAlpha blends the new background color with the old one, alpha being the
+// weight of the new color.
+func BkgndAlpha(alpha float32) BkgndFlag {
+	return BkgndAlph | BkgndFlag(uint8(alpha*255))<<8
+}
+
+// BkgndAddAlpha adds the new background color, scaled by alpha, to the old one.
+func BkgndAddAlpha(alpha float32) BkgndFlag {
+	return BkgndAdda | BkgndFlag(uint8(alpha*255))<<8
+}
+
+// Alignment is the alignment of printed text.  The values match libtcod's
+// TCOD_alignment_t.
+type Alignment uint32
+
+const (
+	Left Alignment = iota
+	Right
+	Center
+)
+
+// ColCtrl is a color control code.  The values match libtcod's TCOD_colctrl_t.
+type ColCtrl uint32
+
+const (
+	COLCTRL_1 = iota + 1
+	COLCTRL_2
+	COLCTRL_3
+	COLCTRL_4
+	COLCTRL_5
+	COLCTRL_FORE_RGB
+	COLCTRL_BACK_RGB
+	COLCTRL_STOP
+
+	COLCTRL_NUMBER = COLCTRL_5
+)
+
+// The code page 437 glyphs used to draw frames.
+const (
+	CHAR_HLINE = 196
+	CHAR_VLINE = 179
+	CHAR_NE    = 191
+	CHAR_NW    = 218
+	CHAR_SE    = 217
+	CHAR_SW    = 192
+)
+
+// Tile is a single console cell, laid out like tcod.Tile.
+type Tile struct {
+	Ch      rune
+	Fg      Color
+	FgAlpha uint8
+	Bg      Color
+	BgAlpha uint8
+}
+
+// NewTile returns an opaque tile with the given glyph and colors.
+func NewTile(ch rune, fg, bg Color) Tile {
+	return Tile{Ch: ch, Fg: fg, FgAlpha: 255, Bg: bg, BgAlpha: 255}
+}
+
+// Grid is the part of a console that Blit reads from and draws to.
+type Grid interface {
+	GetWidth() int
+	GetHeight() int
+	GetChar(x, y int) rune
+	GetCharForeground(x, y int) Color
+	GetCharBackground(x, y int) Color
+	PutCharEx(x, y int, c rune, fore, back Color)
+}
+
+// IConsole mirrors tcod.IConsole, without the libtcod console handle.
+type IConsole interface {
+	Grid
+	GetDefaultBackground() Color
+	GetDefaultForeground() Color
+	SetDefaultForeground(color Color)
+	SetDefaultBackground(color Color)
+	Clear()
+	SetCharBackground(x, y int, color Color, flag BkgndFlag)
+	SetCharForeground(x, y int, color Color)
+	SetChar(x, y int, c rune)
+	PutChar(x, y int, c rune, flag BkgndFlag)
+	Print(x, y int, fmts string, v ...interface{})
+	PrintEx(x, y int, flag BkgndFlag, alignment Alignment, fmts string, v ...interface{})
+	PrintRect(x, y, w, h int, fmts string, v ...interface{}) int
+	PrintRectEx(x, y, w, h int, flag BkgndFlag, alignment Alignment, fmts string, v ...interface{}) int
+	HeightRect(x, y, w, h int, fmts string, v ...interface{}) int
+	SetBackgroundFlag(flag BkgndFlag)
+	GetBackgroundFlag() BkgndFlag
+	SetAlignment(alignment Alignment)
+	GetAlignment() Alignment
+	Rect(x, y, w, h int, clear bool, flag BkgndFlag)
+	Hline(x, y, l int, flag BkgndFlag)
+	Vline(x, y, l int, flag BkgndFlag)
+	PrintFrame(x, y, w, h int, empty bool, flag BkgndFlag, fmts string, v ...interface{})
+	SetKeyColor(color Color)
+	Blit(xSrc, ySrc, wSrc, hSrc int, dst Grid, xDst, yDst int, foregroundAlpha, backgroundAlpha float32)
+}
+
+//
+// Console
+//
+
+// Console is an in-memory console.
+type Console struct {
+	w, h      int
+	tiles     []Tile
+	fore      Color
+	back      Color
+	flag      BkgndFlag
+	alignment Alignment
+	keyColor  *Color
+	ctrlFore  [COLCTRL_NUMBER]Color
+	ctrlBack  [COLCTRL_NUMBER]Color
+}
+
+// NewConsole creates a w x h console, cleared to white on black.
+func NewConsole(w, h int) *Console {
+	console := &Console{
+		w:         w,
+		h:         h,
+		tiles:     make([]Tile, w*h),
+		fore:      white,
+		back:      black,
+		flag:      BkgndNone,
+		alignment: Left,
+	}
+	for i := range console.ctrlFore {
+		console.ctrlFore[i] = white
+		console.ctrlBack[i] = black
+	}
+	console.Clear()
+	return console
+}
+
+func (console *Console) inBounds(x, y int) bool {
+	return x >= 0 && y >= 0 && x < console.w && y < console.h
+}
+
+func (console *Console) tile(x, y int) *Tile {
+	if !console.inBounds(x, y) {
+		return nil
+	}
+	return &console.tiles[y*console.w+x]
+}
+
+func (console *Console) GetDefaultBackground() Color {
+	return console.back
+}
+
+func (console *Console) GetDefaultForeground() Color {
+	return console.fore
+}
+
+func (console *Console) SetDefaultForeground(color Color) {
+	console.fore = color
+}
+
+func (console *Console) SetDefaultBackground(color Color) {
+	console.back = color
+}
+
+func (console *Console) Clear() {
+	for i := range console.tiles {
+		console.tiles[i] = NewTile(' ', console.fore, console.back)
+	}
+}
+
+func (console *Console) GetCharBackground(x, y int) Color {
+	if t := console.tile(x, y); t != nil {
+		return t.Bg
+	}
+	return black
+}
+
+func (console *Console) GetCharForeground(x, y int) Color {
+	if t := console.tile(x, y); t != nil {
+		return t.Fg
+	}
+	return black
+}
+
+func (console *Console) SetCharBackground(x, y int, color Color, flag BkgndFlag) {
+	if t := console.tile(x, y); t != nil {
+		if flag == BkgndDefault {
+			flag = console.flag
+		}
+		t.Bg = blendBackground(t.Bg, color, flag)
+	}
+}
+
+func (console *Console) SetCharForeground(x, y int, color Color) {
+	if t := console.tile(x, y); t != nil {
+		t.Fg = color
+	}
+}
+
+func (console *Console) SetChar(x, y int, c rune) {
+	if t := console.tile(x, y); t != nil {
+		t.Ch = c
+	}
+}
+
+func (console *Console) PutChar(x, y int, c rune, flag BkgndFlag) {
+	if t := console.tile(x, y); t != nil {
+		t.Ch = c
+		t.Fg = console.fore
+		console.SetCharBackground(x, y, console.back, flag)
+	}
+}
+
+func (console *Console) PutCharEx(x, y int, c rune, fore, back Color) {
+	if t := console.tile(x, y); t != nil {
+		t.Ch = c
+		t.Fg = fore
+		t.Bg = back
+	}
+}
+
+func (console *Console) Print(x, y int, fmts string, v ...interface{}) {
+	console.printInternal(x, y, 0, 0, console.flag, console.alignment, fmt.Sprintf(fmts, v...), false, false)
+}
+
+func (console *Console) PrintEx(x, y int, flag BkgndFlag, alignment Alignment, fmts string, v ...interface{}) {
+	console.printInternal(x, y, 0, 0, flag, alignment, fmt.Sprintf(fmts, v...), false, false)
+}
+
+func (console *Console) PrintRect(x, y, w, h int, fmts string, v ...interface{}) int {
+	return console.printInternal(x, y, w, h, console.flag, console.alignment, fmt.Sprintf(fmts, v...), true, false)
+}
+
+func (console *Console) PrintRectEx(x, y, w, h int, flag BkgndFlag, alignment Alignment, fmts string, v ...interface{}) int {
+	return console.printInternal(x, y, w, h, flag, alignment, fmt.Sprintf(fmts, v...), true, false)
+}
+
+func (console *Console) HeightRect(x, y, w, h int, fmts string, v ...interface{}) int {
+	return console.printInternal(x, y, w, h, BkgndNone, Left, fmt.Sprintf(fmts, v...), true, true)
+}
+
+func (console *Console) SetBackgroundFlag(flag BkgndFlag) {
+	console.flag = flag
+}
+
+func (console *Console) GetBackgroundFlag() BkgndFlag {
+	return console.flag
+}
+
+func (console *Console) SetAlignment(alignment Alignment) {
+	console.alignment = alignment
+}
+
+func (console *Console) GetAlignment() Alignment {
+	return console.alignment
+}
+
+func (console *Console) Rect(x, y, w, h int, clear bool, flag BkgndFlag) {
+	x0, y0 := max(x, 0), max(y, 0)
+	x1, y1 := min(x+w, console.w), min(y+h, console.h)
+	for cy := y0; cy < y1; cy++ {
+		for cx := x0; cx < x1; cx++ {
+			console.SetCharBackground(cx, cy, console.back, flag)
+			if clear {
+				console.tile(cx, cy).Ch = ' '
+			}
+		}
+	}
+}
+
+func (console *Console) Hline(x, y, l int, flag BkgndFlag) {
+	for i := x; i < x+l; i++ {
+		console.PutChar(i, y, CHAR_HLINE, flag)
+	}
+}
+
+func (console *Console) Vline(x, y, l int, flag BkgndFlag) {
+	for i := y; i < y+l; i++ {
+		console.PutChar(x, i, CHAR_VLINE, flag)
+	}
+}
+
+func (console *Console) PrintFrame(x, y, w, h int, empty bool, flag BkgndFlag, fmts string, v ...interface{}) {
+	console.PutChar(x, y, CHAR_NW, flag)
+	console.PutChar(x+w-1, y, CHAR_NE, flag)
+	console.PutChar(x, y+h-1, CHAR_SW, flag)
+	console.PutChar(x+w-1, y+h-1, CHAR_SE, flag)
+	console.Hline(x+1, y, w-2, flag)
+	console.Hline(x+1, y+h-1, w-2, flag)
+	if h > 2 {
+		console.Vline(x, y+1, h-2, flag)
+		console.Vline(x+w-1, y+1, h-2, flag)
+		if empty {
+			console.Rect(x+1, y+1, w-2, h-2, true, flag)
+		}
+	}
+
+	s := fmt.Sprintf(fmts, v...)
+	xs := x + (w-VisibleLength(s)-2)/2
+	console.fore, console.back = console.back, console.fore
+	console.Print(xs, y, " %s ", s)
+	console.fore, console.back = console.back, console.fore
+}
+
+func (console *Console) GetChar(x, y int) rune {
+	if t := console.tile(x, y); t != nil {
+		return t.Ch
+	}
+	return 0
+}
+
+func (console *Console) GetWidth() int {
+	return console.w
+}
+
+func (console *Console) GetHeight() int {
+	return console.h
+}
+
+func (console *Console) SetKeyColor(color Color) {
+	console.keyColor = &color
+}
+
+// SetColorControl sets the colors used by a color control code when printing to
+// this console.  Unlike tcod.RootConsole.SetColorControl, the colors only apply
+// to this console.
+func (console *Console) SetColorControl(ctrl ColCtrl, fore, back Color) {
+	if ctrl >= COLCTRL_1 && ctrl <= COLCTRL_NUMBER {
+		console.ctrlFore[ctrl-1] = fore
+		console.ctrlBack[ctrl-1] = back
+	}
+}
+
+func (console *Console) Blit(xSrc, ySrc, wSrc, hSrc int, dst Grid, xDst, yDst int, foregroundAlpha, backgroundAlpha float32) {
+	Blit(console, console.keyColor, xSrc, ySrc, wSrc, hSrc, dst, xDst, yDst, foregroundAlpha, backgroundAlpha)
+}
+
+// Tiles returns the console's tiles in row-major order.  Changes to the slice are
+// changes to the console.
+func (console *Console) Tiles() []Tile {
+	return console.tiles
+}
+
+// String returns the glyphs on the console, one line per row, which is handy for
+// comparing screen layouts against golden files.
+func (console *Console) String() string {
+	var b strings.Builder
+	for y := 0; y < console.h; y++ {
+		for x := 0; x < console.w; x++ {
+			b.WriteRune(console.tiles[y*console.w+x].Ch)
+		}
+		b.WriteByte('\n')
+	}
+	return b.String()
+}
+
+//
+// Printing
+//
+
+// printInternal is a port of libtcod's TCOD_console_print_internal.  It prints s
+// within the rw x rh rectangle anchored at x, y, splitting lines at spaces when
+// canSplit is set, and returns the number of lines the text takes up.  If
+// countOnly is set, nothing is drawn.
+func (console *Console) printInternal(x, y, rw, rh int, flag BkgndFlag, alignment Alignment, s string,
+	canSplit, countOnly bool) int {
+	if !console.inBounds(x, y) {
+		return 0
+	}
+	if rh == 0 {
+		rh = console.h - y
+	}
+	if rw == 0 {
+		switch alignment {
+		case Left:
+			rw = console.w - x
+		case Right:
+			rw = x + 1
+		default:
+			rw = console.w
+		}
+	}
+
+	oldFore, oldBack := console.fore, console.back
+	defer func() {
+		console.fore, console.back = oldFore, oldBack
+	}()
+
+	miny, maxy := y, console.h-1
+	if rh > 0 {
+		maxy = min(maxy, y+rh-1)
+	}
+	var minx, maxx int
+	switch alignment {
+	case Left:
+		minx, maxx = max(0, x), min(console.w-1, x+rw-1)
+	case Right:
+		minx, maxx = max(0, x-rw+1), min(console.w-1, x)
+	default:
+		minx, maxx = max(0, x-rw/2), min(console.w-1, x+rw/2)
+	}
+
+	text := glyphs(s)
+	cy := y
+	for {
+		// get the \n delimited sub-message
+		line, next := text, -1
+		for i, c := range text {
+			if c == '\n' {
+				line, next = text[:i], i+1
+				break
+			}
+		}
+
+		cl := visibleLength(line)
+		cx := alignedX(alignment, x, cl)
+
+		// skip the line if it's entirely outside the rectangle
+		if cy >= miny && cy <= maxy && cx <= maxx && cx+cl-1 >= minx {
+			split := -1
+			if canSplit {
+				if cx < minx {
+					if alignment == Center {
+						split = forward(line, cl-2*(minx-cx))
+					} else {
+						split = forward(line, cl-(minx-cx))
+					}
+				} else if alignment == Center {
+					if cx+cl/2 > maxx+1 {
+						split = forward(line, maxx+1-cx)
+					}
+				} else if cx+cl > maxx+1 {
+					split = forward(line, maxx+1-cx)
+				}
+			}
+
+			if split >= 0 && split < len(line) {
+				// back up to the last space, or split mid-word if there isn't one
+				space := split
+				for space > 0 && line[space] != ' ' {
+					space--
+				}
+				if line[space] == ' ' {
+					line, next = line[:space], space+1
+				} else {
+					line, next = line[:split], split
+				}
+				cl = visibleLength(line)
+				cx = alignedX(alignment, x, cl)
+			}
+
+			if !countOnly {
+				console.printLine(line, cx, cy, minx, maxx, flag, oldFore, oldBack)
+			}
+		}
+
+		cy++
+		if next < 0 || cy >= console.h || cy >= y+rh {
+			break
+		}
+		text = text[next:]
+	}
+
+	return cy - y
+}
+
+// printLine draws a single line of glyphs starting at cx, cy, clipped to the minx,
+// maxx columns, applying any color control codes along the way.
+func (console *Console) printLine(line []rune, cx, cy, minx, maxx int, flag BkgndFlag, oldFore, oldBack Color) {
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		switch {
+		case c >= COLCTRL_1 && c <= COLCTRL_NUMBER:
+			console.fore = console.ctrlFore[c-1]
+			console.back = console.ctrlBack[c-1]
+		case c == COLCTRL_FORE_RGB && i+3 < len(line):
+			console.fore = Color{uint8(line[i+1]), uint8(line[i+2]), uint8(line[i+3])}
+			i += 3
+		case c == COLCTRL_BACK_RGB && i+3 < len(line):
+			console.back = Color{uint8(line[i+1]), uint8(line[i+2]), uint8(line[i+3])}
+			i += 3
+		case c == COLCTRL_STOP:
+			console.fore, console.back = oldFore, oldBack
+		default:
+			if cx >= minx && cx <= maxx {
+				console.PutChar(cx, cy, c, flag)
+			}
+			cx++
+		}
+	}
+}
+
+// VisibleLength returns the number of cells s takes up when printed on one line,
+// not counting color control codes.
+func VisibleLength(s string) int {
+	return visibleLength(glyphs(s))
+}
+
+// glyphs decodes a UTF-8 string into the glyphs printed for it.  The three bytes
+// following a COLCTRL_FORE_RGB or COLCTRL_BACK_RGB code are color components
+// rather than UTF-8, so they're copied as is.
+func glyphs(s string) []rune {
+	result := make([]rune, 0, len(s))
+	for i := 0; i < len(s); {
+		c := s[i]
+		if (c == COLCTRL_FORE_RGB || c == COLCTRL_BACK_RGB) && i+3 < len(s) {
+			result = append(result, rune(c), rune(s[i+1]), rune(s[i+2]), rune(s[i+3]))
+			i += 4
+			continue
+		}
+		r, size := utf8.DecodeRuneInString(s[i:])
+		result = append(result, r)
+		i += size
+	}
+	return result
+}
+
+// visibleLength counts the glyphs in a line, ignoring color control codes.
+func visibleLength(line []rune) int {
+	return len(line) - controlLength(line, len(line))
+}
+
+// controlLength counts the color control codes, and their RGB arguments, in the
+// first n entries of line.
+func controlLength(line []rune, n int) int {
+	count := 0
+	for i := 0; i < n && i < len(line); i++ {
+		switch c := line[i]; {
+		case c == COLCTRL_FORE_RGB || c == COLCTRL_BACK_RGB:
+			count += 4
+			i += 3
+		case c >= COLCTRL_1 && c <= COLCTRL_STOP:
+			count++
+		}
+	}
+	return count
+}
+
+// forward returns the index in line just past the first n visible glyphs.
+func forward(line []rune, n int) int {
+	i := 0
+	for ; i < len(line) && n > 0; i++ {
+		switch c := line[i]; {
+		case c == COLCTRL_FORE_RGB || c == COLCTRL_BACK_RGB:
+			i += 3
+		case c >= COLCTRL_1 && c <= COLCTRL_STOP:
+		default:
+			n--
+		}
+	}
+	if i > len(line) {
+		return len(line)
+	}
+	return i
+}
+
+func alignedX(alignment Alignment, x, length int) int {
+	switch alignment {
+	case Right:
+		return x - length + 1
+	case Center:
+		return x - length/2
+	default:
+		return x
+	}
+}
+
+//
+// Blending
+//
+
+// blendBackground combines the old background color with a new one according to
+// the background flag, following TCOD_console_set_char_background.
+func blendBackground(old, color Color, flag BkgndFlag) Color {
+	alpha := int(flag >> 8)
+	blend := func(f func(o, c int) int) Color {
+		return Color{
+			uint8(clamp8(f(int(old.R), int(color.R)))),
+			uint8(clamp8(f(int(old.G), int(color.G)))),
+			uint8(clamp8(f(int(old.B), int(color.B)))),
+		}
+	}
+
+	switch flag & 0xff {
+	case BkgndSet:
+		return color
+	case BkgndMultiply:
+		return blend(func(o, c int) int { return o * c / 255 })
+	case BkgndLighten:
+		return blend(max)
+	case BkgndDarken:
+		return blend(min)
+	case BkgndScreen:
+		return blend(func(o, c int) int { return 255 - (255-o)*(255-c)/255 })
+	case BkgndColorDodge:
+		return blend(func(o, c int) int {
+			if o != 255 {
+				return 255 * c / (255 - o)
+			}
+			return 255
+		})
+	case BkgndColorBurn:
+		return blend(func(o, c int) int {
+			if c > 0 {
+				return 255 - 255*(255-o)/c
+			}
+			return 0
+		})
+	case BkgndAdd:
+		return blend(func(o, c int) int { return o + c })
+	case BkgndAdda:
+		return blend(func(o, c int) int { return o + alpha*c/255 })
+	case BkgndBurn:
+		return blend(func(o, c int) int { return o + c - 255 })
+	case BkgndOverlay:
+		return blend(func(o, c int) int {
+			if c <= 128 {
+				return 2 * c * o / 255
+			}
+			return 255 - 2*(255-c)*(255-o)/255
+		})
+	case BkgndAlph:
+		return blend(func(o, c int) int { return ((255-alpha)*o + alpha*c) / 255 })
+	}
+	return old
+}
+
+// lerpColor is a pure Go version of tcod.Color.Lerp.
+func lerpColor(c1, c2 Color, coef float32) Color {
+	return Color{
+		uint8(float32(c1.R) + float32(int(c2.R)-int(c1.R))*coef),
+		uint8(float32(c1.G) + float32(int(c2.G)-int(c1.G))*coef),
+		uint8(float32(c1.B) + float32(int(c2.B)-int(c1.B))*coef),
+	}
+}
+
+// Blit copies a region of src onto dst cell by cell, following the blending rules
+// of TCOD_console_blit.  Cells whose background is keyColor are skipped, unless
+// keyColor is nil.
+func Blit(src Grid, keyColor *Color, xSrc, ySrc, wSrc, hSrc int, dst Grid, xDst, yDst int,
+	foregroundAlpha, backgroundAlpha float32) {
+	if wSrc == 0 {
+		wSrc = src.GetWidth()
+	}
+	if hSrc == 0 {
+		hSrc = src.GetHeight()
+	}
+
+	for cy := ySrc; cy < ySrc+hSrc; cy++ {
+		for cx := xSrc; cx < xSrc+wSrc; cx++ {
+			dx, dy := cx-xSrc+xDst, cy-ySrc+yDst
+			if cx < 0 || cy < 0 || cx >= src.GetWidth() || cy >= src.GetHeight() ||
+				dx < 0 || dy < 0 || dx >= dst.GetWidth() || dy >= dst.GetHeight() {
+				continue
+			}
+
+			srcCh, srcFg, srcBg := src.GetChar(cx, cy), src.GetCharForeground(cx, cy), src.GetCharBackground(cx, cy)
+			if keyColor != nil && srcBg == *keyColor {
+				continue
+			}
+
+			if foregroundAlpha == 1 && backgroundAlpha == 1 {
+				dst.PutCharEx(dx, dy, srcCh, srcFg, srcBg)
+				continue
+			}
+
+			ch, fg, bg := dst.GetChar(dx, dy), dst.GetCharForeground(dx, dy), dst.GetCharBackground(dx, dy)
+			bg = lerpColor(bg, srcBg, backgroundAlpha)
+			switch {
+			case srcCh == ' ':
+				// source is a space, so blend the destination foreground with the
+				// source background
+				fg = lerpColor(fg, srcBg, backgroundAlpha)
+			case ch == ' ':
+				ch = srcCh
+				fg = lerpColor(bg, srcFg, foregroundAlpha)
+			case ch == srcCh:
+				fg = lerpColor(fg, srcFg, foregroundAlpha)
+			case foregroundAlpha < 0.5:
+				// pick the character with the most contribution
+				fg = lerpColor(fg, bg, foregroundAlpha*2)
+			default:
+				ch = srcCh
+				fg = lerpColor(bg, srcFg, (foregroundAlpha-0.5)*2)
+			}
+			dst.PutCharEx(dx, dy, ch, fg, bg)
+		}
+	}
+}
+
+func clamp8(v int) int {
+	return max(0, min(255, v))
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func max(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
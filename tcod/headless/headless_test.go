@@ -0,0 +1,198 @@
+package headless
+
+import (
+	"strings"
+	"testing"
+)
+
+// rows returns the console's glyphs one row at a time.
+func rows(console *Console) []string {
+	return strings.Split(strings.TrimSuffix(console.String(), "\n"), "\n")
+}
+
+func checkRows(t *testing.T, name string, console *Console, want ...string) {
+	t.Helper()
+	got := rows(console)
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("%s: row %d is %q, want %q", name, i, got[i], want[i])
+		}
+	}
+}
+
+func TestPrintAlignment(t *testing.T) {
+	console := NewConsole(10, 3)
+	console.PrintEx(2, 0, BkgndNone, Left, "abc")
+	console.PrintEx(9, 1, BkgndNone, Right, "abc")
+	console.PrintEx(5, 2, BkgndNone, Center, "abc")
+	checkRows(t, "Print", console,
+		"  abc     ",
+		"       abc",
+		"    abc   ")
+
+	// Text running off the console is clipped rather than wrapped.
+	console.Clear()
+	console.PrintEx(7, 0, BkgndNone, Left, "abcdef")
+	console.PrintEx(1, 1, BkgndNone, Right, "abcdef")
+	checkRows(t, "clipped Print", console,
+		"       abc",
+		"ef        ")
+}
+
+func TestPrintRect(t *testing.T) {
+	tests := []struct {
+		name       string
+		x, y, w, h int
+		alignment  Alignment
+		s          string
+		lines      int
+		want       []string
+	}{
+		{"wrap at spaces", 0, 0, 10, 0, Left, "The quick brown fox jumps", 3,
+			[]string{"The quick ", "brown fox ", "jumps     ", "          "}},
+		{"split long words", 0, 0, 4, 0, Left, "abcdefghij", 3,
+			[]string{"abcd      ", "efgh      ", "ij        ", "          "}},
+		{"height limit", 0, 0, 10, 2, Left, "The quick brown fox jumps", 2,
+			[]string{"The quick ", "brown fox ", "          ", "          "}},
+		{"newlines", 1, 1, 0, 0, Left, "ab\ncd", 2,
+			[]string{"          ", " ab       ", " cd       ", "          "}},
+		{"right aligned", 9, 0, 5, 0, Right, "one two three", 3,
+			[]string{"       one", "       two", "     three", "          "}},
+		{"centered", 5, 0, 6, 0, Center, "ab cd ef", 2,
+			[]string{"   ab cd  ", "    ef    ", "          ", "          "}},
+	}
+	for _, test := range tests {
+		console := NewConsole(10, 4)
+		lines := console.PrintRectEx(test.x, test.y, test.w, test.h, BkgndNone, test.alignment, test.s)
+		if lines != test.lines {
+			t.Errorf("%s: printed %d lines, want %d", test.name, lines, test.lines)
+		}
+		checkRows(t, test.name, console, test.want...)
+
+		if height := console.HeightRect(test.x, test.y, test.w, test.h, test.s); test.alignment == Left && height != test.lines {
+			t.Errorf("%s: HeightRect is %d, want %d", test.name, height, test.lines)
+		}
+	}
+
+	// HeightRect doesn't draw anything.
+	console := NewConsole(10, 4)
+	console.HeightRect(0, 0, 10, 0, "The quick brown fox jumps")
+	checkRows(t, "HeightRect", console, "          ")
+}
+
+func TestPrintColorControls(t *testing.T) {
+	console := NewConsole(5, 1)
+	console.SetColorControl(COLCTRL_1, Color{0, 255, 0}, Color{0, 0, 255})
+	console.Print(0, 0, "a\x06\xff\x01\x01b\x08c\x01d")
+	checkRows(t, "color controls", console, "abcd ")
+
+	for _, want := range []struct {
+		x        int
+		fg, back Color
+	}{
+		{0, white, black},
+		{1, Color{255, 1, 1}, black},
+		{2, white, black},
+		{3, Color{0, 255, 0}, black}, // BkgndNone leaves the background alone
+	} {
+		if fg := console.GetCharForeground(want.x, 0); fg != want.fg {
+			t.Errorf("cell %d foreground is %v, want %v", want.x, fg, want.fg)
+		}
+		if bg := console.GetCharBackground(want.x, 0); bg != want.back {
+			t.Errorf("cell %d background is %v, want %v", want.x, bg, want.back)
+		}
+	}
+	if fg := console.GetDefaultForeground(); fg != white {
+		t.Errorf("printing left the default foreground at %v", fg)
+	}
+}
+
+func TestBackgroundFlags(t *testing.T) {
+	old, color := Color{100, 150, 200}, Color{200, 100, 50}
+	tests := []struct {
+		name string
+		flag BkgndFlag
+		want Color
+	}{
+		{"none", BkgndNone, old},
+		{"set", BkgndSet, color},
+		{"multiply", BkgndMultiply, Color{78, 58, 39}},
+		{"lighten", BkgndLighten, Color{200, 150, 200}},
+		{"darken", BkgndDarken, Color{100, 100, 50}},
+		{"screen", BkgndScreen, Color{222, 192, 211}},
+		{"color dodge", BkgndColorDodge, Color{255, 242, 231}},
+		{"color burn", BkgndColorBurn, Color{58, 0, 0}},
+		{"add", BkgndAdd, Color{255, 250, 250}},
+		{"add alpha", BkgndAddAlpha(0.5), Color{199, 199, 224}},
+		{"burn", BkgndBurn, Color{45, 0, 0}},
+		{"overlay", BkgndOverlay, Color{189, 117, 78}},
+		{"alpha", BkgndAlpha(0.5), Color{149, 125, 125}},
+	}
+	for _, test := range tests {
+		console := NewConsole(1, 1)
+		console.SetCharBackground(0, 0, old, BkgndSet)
+		console.SetCharBackground(0, 0, color, test.flag)
+		if got := console.GetCharBackground(0, 0); got != test.want {
+			t.Errorf("%s: background is %v, want %v", test.name, got, test.want)
+		}
+
+		// BkgndDefault uses the console's own flag.
+		console.SetCharBackground(0, 0, old, BkgndSet)
+		console.SetBackgroundFlag(test.flag)
+		console.SetCharBackground(0, 0, color, BkgndDefault)
+		if got := console.GetCharBackground(0, 0); got != test.want {
+			t.Errorf("%s by default: background is %v, want %v", test.name, got, test.want)
+		}
+	}
+}
+
+func TestBlitAlpha(t *testing.T) {
+	red, blue, grey := Color{200, 0, 0}, Color{0, 0, 200}, Color{100, 100, 100}
+	tests := []struct {
+		name           string
+		srcCh, dstCh   rune
+		dstFg, dstBg   Color
+		fgAlpha, alpha float32
+		ch             rune
+		fg, bg         Color
+	}{
+		{"opaque", 'a', 'x', red, grey, 1, 1, 'a', white, blue},
+		{"onto a space", 'a', ' ', black, grey, 0.5, 0.5, 'a', Color{152, 152, 202}, Color{50, 50, 150}},
+		{"from a space", ' ', 'x', red, black, 0.5, 0.5, 'x', Color{100, 0, 100}, Color{0, 0, 100}},
+		{"same glyph", 'x', 'x', red, black, 0.5, 0, 'x', Color{227, 127, 127}, black},
+		{"faint glyph", 'a', 'x', red, black, 0.25, 0, 'x', Color{100, 0, 0}, black},
+		{"strong glyph", 'a', 'x', red, black, 0.75, 0, 'a', Color{127, 127, 127}, black},
+	}
+	for _, test := range tests {
+		src, dst := NewConsole(1, 1), NewConsole(1, 1)
+		src.PutCharEx(0, 0, test.srcCh, white, blue)
+		dst.PutCharEx(0, 0, test.dstCh, test.dstFg, test.dstBg)
+		src.Blit(0, 0, 0, 0, dst, 0, 0, test.fgAlpha, test.alpha)
+
+		if ch := dst.GetChar(0, 0); ch != test.ch {
+			t.Errorf("%s: glyph is %q, want %q", test.name, ch, test.ch)
+		}
+		if fg := dst.GetCharForeground(0, 0); fg != test.fg {
+			t.Errorf("%s: foreground is %v, want %v", test.name, fg, test.fg)
+		}
+		if bg := dst.GetCharBackground(0, 0); bg != test.bg {
+			t.Errorf("%s: background is %v, want %v", test.name, bg, test.bg)
+		}
+	}
+}
+
+func TestBlitRegion(t *testing.T) {
+	src := NewConsole(4, 2)
+	src.Print(0, 0, "abcd")
+	src.Print(0, 1, "efgh")
+	src.SetCharBackground(1, 0, Color{255, 0, 255}, BkgndSet)
+	src.SetKeyColor(Color{255, 0, 255})
+
+	dst := NewConsole(5, 3)
+	dst.Print(0, 0, ".....")
+	src.Blit(0, 0, 3, 0, dst, 2, 1, 1, 1)
+	checkRows(t, "Blit", dst,
+		".....",
+		"  a c",
+		"  efg")
+}
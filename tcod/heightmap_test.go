@@ -0,0 +1,261 @@
+package tcod
+
+import (
+	"bytes"
+	"image"
+	"image/png"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const benchHeightMapW, benchHeightMapH = 256, 256
+
+// benchmarkHeightMap times scale, which halves every value in the heightmap and
+// adds one.
+func benchmarkHeightMap(b *testing.B, scale func(heightMap *HeightMap)) {
+	heightMap := NewHeightMap(benchHeightMapW, benchHeightMapH)
+	for i := 0; i < b.N; i++ {
+		scale(heightMap)
+	}
+}
+
+// BenchmarkHeightMapValues scales every value through the Values slice,
+// through copies made by GetValues and SetValues, and with two cgo calls per
+// cell, for comparison.
+func BenchmarkHeightMapValues(b *testing.B) {
+	b.Run("Values", func(b *testing.B) {
+		benchmarkHeightMap(b, func(heightMap *HeightMap) {
+			values := heightMap.Values()
+			for j := range values {
+				values[j] = values[j]*0.5 + 1
+			}
+		})
+	})
+	b.Run("GetSetValues", func(b *testing.B) {
+		benchmarkHeightMap(b, func(heightMap *HeightMap) {
+			values := heightMap.GetValues()
+			for j := range values {
+				values[j] = values[j]*0.5 + 1
+			}
+			heightMap.SetValues(values)
+		})
+	})
+	b.Run("PerCell", func(b *testing.B) {
+		benchmarkHeightMap(b, func(heightMap *HeightMap) {
+			for y := 0; y < benchHeightMapH; y++ {
+				for x := 0; x < benchHeightMapW; x++ {
+					heightMap.SetValue(x, y, heightMap.GetValue(x, y)*0.5+1)
+				}
+			}
+		})
+	})
+}
+
+// checkHeightMapValues fails the test unless the heightmap holds want, read
+// through GetValue, Values and GetValues.
+func checkHeightMapValues(t *testing.T, name string, heightMap *HeightMap, want []float32) {
+	t.Helper()
+	w := heightMap.GetWidth()
+	for i, v := range want {
+		if got := heightMap.GetValue(i%w, i/w); got != v {
+			t.Errorf("%s: GetValue(%d, %d) is %v, want %v", name, i%w, i/w, got, v)
+		}
+	}
+	if got := heightMap.Values(); !reflect.DeepEqual(got, want) {
+		t.Errorf("%s: Values is %v, want %v", name, got, want)
+	}
+	if got := heightMap.GetValues(); !reflect.DeepEqual(got, want) {
+		t.Errorf("%s: GetValues is %v, want %v", name, got, want)
+	}
+}
+
+func TestHeightMapValues(t *testing.T) {
+	heightMap := NewHeightMap(3, 2)
+	checkHeightMapValues(t, "new", heightMap, []float32{0, 0, 0, 0, 0, 0})
+
+	// The values are in row-major order.
+	heightMap.SetValue(1, 0, 1.5)
+	heightMap.SetValue(0, 1, -2)
+	heightMap.SetValue(2, 1, 7)
+	checkHeightMapValues(t, "SetValue", heightMap, []float32{0, 1.5, 0, -2, 0, 7})
+
+	// Values shares the heightmap's buffer.
+	values := heightMap.Values()
+	values[0], values[4] = 3, 0.25
+	checkHeightMapValues(t, "Values", heightMap, []float32{3, 1.5, 0, -2, 0.25, 7})
+
+	// GetValues is a copy.
+	copied := heightMap.GetValues()
+	copied[1] = 100
+	checkHeightMapValues(t, "GetValues", heightMap, []float32{3, 1.5, 0, -2, 0.25, 7})
+
+	heightMap.SetValues([]float32{6, 5, 4, 3, 2, 1})
+	checkHeightMapValues(t, "SetValues", heightMap, []float32{6, 5, 4, 3, 2, 1})
+
+	// A short slice sets the first values, and a long one is cut off.
+	heightMap.SetValues([]float32{-1, -2})
+	checkHeightMapValues(t, "short SetValues", heightMap, []float32{-1, -2, 4, 3, 2, 1})
+	heightMap.SetValues([]float32{9, 8, 7, 6, 5, 4, 3, 2})
+	checkHeightMapValues(t, "long SetValues", heightMap, []float32{9, 8, 7, 6, 5, 4})
+}
+
+// libtcod declares TCOD_heightmap_heat_erosion but never built it (it's
+// commented out of heightmap.h), so there's nothing to compare HeatErosion
+// with; these results are worked out by hand instead.
+func TestHeightMapHeatErosion(t *testing.T) {
+	tests := []struct {
+		name                                   string
+		w                                      int
+		values                                 []float32
+		nbPass                                 int
+		minSlope, erosionCoef, aggregationCoef float32
+		want                                   []float32
+	}{
+		// 4 is 3 past the slope, so it loses half of that, and half of what
+		// it loses settles below it; the middle cell is then within the slope.
+		{"one pass", 3, []float32{4, 0, 0}, 1, 1, 1, 0.5,
+			[]float32{2.5, 0.75, 0}},
+		{"two passes", 3, []float32{4, 0, 0}, 2, 1, 1, 0.5,
+			[]float32{2.125, 0.9375, 0}},
+		{"no passes", 3, []float32{4, 0, 0}, 0, 1, 1, 0.5,
+			[]float32{4, 0, 0}},
+		{"flat", 3, []float32{4, 0, 0}, 1, 4, 1, 0.5,
+			[]float32{4, 0, 0}},
+		// Of the equally low neighbours, the first, diagonally up and left,
+		// takes all the material.
+		{"peak", 3, []float32{0, 0, 0, 0, 9, 0, 0, 0, 0}, 1, 0, 1, 1,
+			[]float32{4.5, 0, 0, 0, 4.5, 0, 0, 0, 0}},
+		{"lost", 3, []float32{0, 0, 0, 0, 9, 0, 0, 0, 0}, 1, 0, 0.5, 0,
+			[]float32{0, 0, 0, 0, 6.75, 0, 0, 0, 0}},
+	}
+	for _, test := range tests {
+		heightMap := NewHeightMap(test.w, len(test.values)/test.w)
+		heightMap.SetValues(test.values)
+		heightMap.HeatErosion(test.nbPass, test.minSlope, test.erosionCoef, test.aggregationCoef)
+		if got := heightMap.GetValues(); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: eroded to %v, want %v", test.name, got, test.want)
+		}
+	}
+}
+
+func TestDecodeHeightMapPGMSize(t *testing.T) {
+	for _, header := range []string{
+		"P5\n0 10\n255\n",
+		"P5\n10 -1\n255\n",
+		"P5\n100000 100000\n255\n",
+		"P5\n4294967297 2\n65535\n",
+	} {
+		if _, err := DecodeHeightMapPGM(strings.NewReader(header), HeightMapRange{}); err == nil {
+			t.Errorf("DecodeHeightMapPGM accepted the header %q", header)
+		}
+	}
+}
+
+// newTestHeightMap returns a 5x3 heightmap rising from -1 to 3.
+func newTestHeightMap() *HeightMap {
+	heightMap := NewHeightMap(5, 3)
+	values := heightMap.Values()
+	for i := range values {
+		values[i] = -1 + float32(i)*4/float32(len(values)-1)
+	}
+	return heightMap
+}
+
+// sameHeights fails the test unless got has want's size and values, give or take
+// tolerance.
+func sameHeights(t *testing.T, name string, got, want *HeightMap, tolerance float64) {
+	t.Helper()
+	if got.GetWidth() != want.GetWidth() || got.GetHeight() != want.GetHeight() {
+		t.Fatalf("%s: read a %dx%d heightmap, want %dx%d", name, got.GetWidth(), got.GetHeight(),
+			want.GetWidth(), want.GetHeight())
+	}
+	gotValues, wantValues := got.GetValues(), want.GetValues()
+	for i := range wantValues {
+		if math.Abs(float64(gotValues[i]-wantValues[i])) > tolerance {
+			t.Errorf("%s: value %d is %v, want %v", name, i, gotValues[i], wantValues[i])
+		}
+	}
+}
+
+func TestHeightMapRoundTrip(t *testing.T) {
+	heightMap := newTestHeightMap()
+	r := HeightMapRange{-1, 3}
+
+	// A 16 bit grey is within half a step of the height.
+	step := float64(r.Max-r.Min) / math.MaxUint16
+	var buf bytes.Buffer
+	if err := heightMap.EncodePNG(&buf, r); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := DecodeHeightMapPNG(&buf, r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sameHeights(t, "PNG", loaded, heightMap, step)
+
+	buf.Reset()
+	if err := heightMap.EncodePGM(&buf, r); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err = DecodeHeightMapPGM(&buf, r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sameHeights(t, "PGM", loaded, heightMap, step)
+
+	buf.Reset()
+	if err := heightMap.EncodeRaw(&buf); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err = DecodeHeightMapRaw(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sameHeights(t, "raw", loaded, heightMap, 0)
+
+	// Without a range, the heightmap's own is written and 0 to 1 read back.
+	normalized := newTestHeightMap()
+	normalized.Normalize()
+	buf.Reset()
+	if err := heightMap.EncodePGM(&buf, HeightMapRange{}); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err = DecodeHeightMapPGM(&buf, HeightMapRange{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sameHeights(t, "PGM without a range", loaded, normalized, 1.0/math.MaxUint16)
+}
+
+func TestDecodeHeightMapPGM8Bit(t *testing.T) {
+	data := "P5\n# a comment\n3 1\n100\n\x00\x32\xff"
+	heightMap, err := DecodeHeightMapPGM(strings.NewReader(data), HeightMapRange{0, 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Greys above maxval count as white.
+	want := []float32{0, 5, 10}
+	got := heightMap.GetValues()
+	for i := range want {
+		if math.Abs(float64(got[i]-want[i])) > 1e-3 {
+			t.Errorf("value %d is %v, want %v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestDecodeHeightMapPNGSize(t *testing.T) {
+	for _, size := range [][2]int{{maxHeightMapSize + 1, 1}, {1, maxHeightMapSize + 1}} {
+		var buf bytes.Buffer
+		if err := png.Encode(&buf, image.NewGray16(image.Rect(0, 0, size[0], size[1]))); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := DecodeHeightMapPNG(&buf, HeightMapRange{}); err == nil || !strings.Contains(err.Error(), "size") {
+			t.Errorf("decoding a %dx%d PNG gave the error %v", size[0], size[1], err)
+		}
+	}
+	if _, err := DecodeHeightMapPNG(strings.NewReader("not a PNG"), HeightMapRange{}); err == nil {
+		t.Error("DecodeHeightMapPNG accepted a file that isn't a PNG")
+	}
+}
@@ -0,0 +1,252 @@
+package tcod
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"math"
+)
+
+//
+// HeightMap import and export
+//
+
+// HeightMapRange maps heights to and from the 16 bit greyscale of PNG and PGM
+// files: Min is black and Max is white.  If Min and Max are equal, encoders use
+// the heightmap's own minimum and maximum, and decoders use 0 to 1.
+type HeightMapRange struct {
+	Min, Max float32
+}
+
+// heightMapRawMagic starts a raw heightmap file.  It's followed by the width and
+// height as little-endian uint32s, then the values as little-endian float32s in
+// row-major order.
+const heightMapRawMagic = "TCODHMF1"
+
+// maxHeightMapSize limits the width and height of heightmaps read from files, so
+// a corrupt or hostile header can't ask for a huge allocation.  At the limit the
+// values alone take 64MB.
+const maxHeightMapSize = 4096
+
+func (r HeightMapRange) forEncoding(heightMap *HeightMap) HeightMapRange {
+	if r.Min == r.Max {
+		r.Min, r.Max = heightMap.GetMinMax()
+	}
+	return r
+}
+
+func (r HeightMapRange) forDecoding() HeightMapRange {
+	if r.Min == r.Max {
+		r.Min, r.Max = 0, 1
+	}
+	return r
+}
+
+// toGrey16 scales a height to 0-65535, clamping heights out of range.
+func (r HeightMapRange) toGrey16(v float32) uint16 {
+	if r.Max == r.Min {
+		return 0
+	}
+	f := (v - r.Min) / (r.Max - r.Min)
+	if f <= 0 {
+		return 0
+	}
+	if f >= 1 {
+		return math.MaxUint16
+	}
+	return uint16(f*math.MaxUint16 + 0.5)
+}
+
+func (r HeightMapRange) fromGrey16(g uint16) float32 {
+	return r.Min + float32(g)/math.MaxUint16*(r.Max-r.Min)
+}
+
+// EncodePNG writes the heightmap as a 16 bit greyscale PNG.
+func (heightMap *HeightMap) EncodePNG(w io.Writer, r HeightMapRange) error {
+	r = r.forEncoding(heightMap)
+	width, height := heightMap.GetWidth(), heightMap.GetHeight()
+	values := heightMap.Values()
+
+	img := image.NewGray16(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			img.SetGray16(x, y, color.Gray16{Y: r.toGrey16(values[y*width+x])})
+		}
+	}
+	return png.Encode(w, img)
+}
+
+// DecodeHeightMapPNG reads a heightmap from a PNG.  Colour images are converted
+// to greyscale.
+func DecodeHeightMapPNG(rd io.Reader, r HeightMapRange) (*HeightMap, error) {
+	// Check the size before the image is decoded, then decode it from the
+	// start, replaying the header the check read.
+	var header bytes.Buffer
+	config, err := png.DecodeConfig(io.TeeReader(rd, &header))
+	if err != nil {
+		return nil, err
+	}
+	if config.Width > maxHeightMapSize || config.Height > maxHeightMapSize {
+		return nil, fmt.Errorf("tcod: invalid heightmap size %dx%d", config.Width, config.Height)
+	}
+
+	img, err := png.Decode(io.MultiReader(&header, rd))
+	if err != nil {
+		return nil, err
+	}
+	r = r.forDecoding()
+
+	bounds := img.Bounds()
+	heightMap := NewHeightMap(bounds.Dx(), bounds.Dy())
+	values := heightMap.Values()
+	for y := 0; y < bounds.Dy(); y++ {
+		for x := 0; x < bounds.Dx(); x++ {
+			grey := color.Gray16Model.Convert(img.At(bounds.Min.X+x, bounds.Min.Y+y)).(color.Gray16)
+			values[y*bounds.Dx()+x] = r.fromGrey16(grey.Y)
+		}
+	}
+	return heightMap, nil
+}
+
+// EncodePGM writes the heightmap as a binary 16 bit PGM (P5) file.
+func (heightMap *HeightMap) EncodePGM(w io.Writer, r HeightMapRange) error {
+	r = r.forEncoding(heightMap)
+	width, height := heightMap.GetWidth(), heightMap.GetHeight()
+
+	bw := bufio.NewWriter(w)
+	if _, err := fmt.Fprintf(bw, "P5\n%d %d\n%d\n", width, height, math.MaxUint16); err != nil {
+		return err
+	}
+	var buf [2]byte
+	for _, v := range heightMap.Values() {
+		binary.BigEndian.PutUint16(buf[:], r.toGrey16(v))
+		if _, err := bw.Write(buf[:]); err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
+
+// DecodeHeightMapPGM reads a heightmap from a binary (P5) PGM file, of either 8 or
+// 16 bits.
+func DecodeHeightMapPGM(rd io.Reader, r HeightMapRange) (*HeightMap, error) {
+	br := bufio.NewReader(rd)
+	var header [4]int
+	magic, err := pgmToken(br)
+	if err != nil {
+		return nil, err
+	}
+	if magic != "P5" {
+		return nil, errors.New("tcod: not a binary PGM file")
+	}
+	for i := 1; i < len(header); i++ {
+		token, err := pgmToken(br)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := fmt.Sscanf(token, "%d", &header[i]); err != nil {
+			return nil, fmt.Errorf("tcod: invalid PGM header: %v", err)
+		}
+	}
+	width, height, maxval := header[1], header[2], header[3]
+	if width <= 0 || height <= 0 || maxval <= 0 || maxval > math.MaxUint16 {
+		return nil, errors.New("tcod: invalid PGM header")
+	}
+	if width > maxHeightMapSize || height > maxHeightMapSize {
+		return nil, fmt.Errorf("tcod: invalid heightmap size %dx%d", width, height)
+	}
+	r = r.forDecoding()
+
+	size := 1
+	if maxval > math.MaxUint8 {
+		size = 2
+	}
+	buf := make([]byte, width*height*size)
+	if _, err := io.ReadFull(br, buf); err != nil {
+		return nil, err
+	}
+
+	heightMap := NewHeightMap(width, height)
+	values := heightMap.Values()
+	for i := range values {
+		var v int
+		if size == 2 {
+			v = int(binary.BigEndian.Uint16(buf[i*2:]))
+		} else {
+			v = int(buf[i])
+		}
+		values[i] = r.fromGrey16(uint16(min(v, maxval) * math.MaxUint16 / maxval))
+	}
+	return heightMap, nil
+}
+
+// pgmToken reads the next whitespace-separated token of a PGM header, skipping
+// comments, and the single whitespace character after it.
+func pgmToken(br *bufio.Reader) (string, error) {
+	var token []byte
+	for {
+		c, err := br.ReadByte()
+		if err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return "", err
+		}
+		switch {
+		case c == '#' && len(token) == 0:
+			if _, err := br.ReadString('\n'); err != nil {
+				return "", err
+			}
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			if len(token) > 0 {
+				return string(token), nil
+			}
+		default:
+			token = append(token, c)
+		}
+	}
+}
+
+// EncodeRaw writes the heightmap's values, unscaled, as little-endian float32s
+// after a short header giving its size.
+func (heightMap *HeightMap) EncodeRaw(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	bw.WriteString(heightMapRawMagic)
+	binary.Write(bw, binary.LittleEndian, uint32(heightMap.GetWidth()))
+	binary.Write(bw, binary.LittleEndian, uint32(heightMap.GetHeight()))
+	if err := binary.Write(bw, binary.LittleEndian, heightMap.Values()); err != nil {
+		return err
+	}
+	return bw.Flush()
+}
+
+// DecodeHeightMapRaw reads a heightmap written by EncodeRaw.
+func DecodeHeightMapRaw(rd io.Reader) (*HeightMap, error) {
+	var header struct {
+		Magic         [len(heightMapRawMagic)]byte
+		Width, Height uint32
+	}
+	if err := binary.Read(rd, binary.LittleEndian, &header); err != nil {
+		return nil, err
+	}
+	if string(header.Magic[:]) != heightMapRawMagic {
+		return nil, errors.New("tcod: not a raw heightmap file")
+	}
+	if header.Width == 0 || header.Height == 0 || header.Width > maxHeightMapSize || header.Height > maxHeightMapSize {
+		return nil, fmt.Errorf("tcod: invalid heightmap size %dx%d", header.Width, header.Height)
+	}
+
+	values := make([]float32, header.Width*header.Height)
+	if err := binary.Read(rd, binary.LittleEndian, values); err != nil {
+		return nil, err
+	}
+	heightMap := NewHeightMap(int(header.Width), int(header.Height))
+	heightMap.SetValues(values)
+	return heightMap, nil
+}
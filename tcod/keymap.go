@@ -0,0 +1,525 @@
+package tcod
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/sbowman/tcod/tcod/keys"
+)
+
+//
+// Key bindings
+//
+
+// Chord is a key together with the modifiers held down with it, such as Alt+Enter.
+// Left and right modifier keys are treated the same.
+type Chord struct {
+	VK    KeyCode
+	C     byte // the character, when VK is keys.Char
+	Alt   bool
+	Ctrl  bool
+	Shift bool
+}
+
+// keyNames are the names of keys in chord strings.  The first name listed for a
+// key is the one used when formatting it.
+var keyNames = []struct {
+	name string
+	vk   KeyCode
+}{
+	{"Escape", keys.ESCAPE}, {"Esc", keys.ESCAPE},
+	{"Backspace", keys.Backspace},
+	{"Tab", keys.Tab},
+	{"Enter", keys.Enter}, {"Return", keys.Enter},
+	{"Space", keys.Space},
+	{"Shift", keys.Shift},
+	{"Control", keys.Control}, {"Ctrl", keys.Control},
+	{"Alt", keys.Alt},
+	{"Pause", keys.Pause},
+	{"CapsLock", keys.CapsLock},
+	{"PageUp", keys.PgUp}, {"PgUp", keys.PgUp},
+	{"PageDown", keys.PgDown}, {"PgDn", keys.PgDown},
+	{"End", keys.End},
+	{"Home", keys.Home},
+	{"Up", keys.Up},
+	{"Down", keys.Down},
+	{"Left", keys.Left},
+	{"Right", keys.Right},
+	{"PrintScreen", keys.PrintScreen},
+	{"Insert", keys.Insert}, {"Ins", keys.Insert},
+	{"Delete", keys.Delete}, {"Del", keys.Delete},
+	{"LWin", keys.LWin},
+	{"RWin", keys.RWin},
+	{"Apps", keys.Apps},
+	{"0", keys.Zero}, {"1", keys.One}, {"2", keys.Two}, {"3", keys.Three}, {"4", keys.Four},
+	{"5", keys.Five}, {"6", keys.Six}, {"7", keys.Seven}, {"8", keys.Eight}, {"9", keys.Nine},
+	{"KP0", keys.KP0}, {"KP1", keys.KP1}, {"KP2", keys.KP2}, {"KP3", keys.KP3}, {"KP4", keys.KP4},
+	{"KP5", keys.KP5}, {"KP6", keys.KP6}, {"KP7", keys.KP7}, {"KP8", keys.KP8}, {"KP9", keys.KP9},
+	{"KPAdd", keys.KPAdd},
+	{"KPSub", keys.KPSub},
+	{"KPMul", keys.KPMul},
+	{"KPDiv", keys.KPDiv},
+	{"KPDec", keys.KPDed},
+	{"KPEnter", keys.KPEnter},
+	{"F1", keys.F1}, {"F2", keys.F2}, {"F3", keys.F3}, {"F4", keys.F4},
+	{"F5", keys.F5}, {"F6", keys.F6}, {"F7", keys.F7}, {"F8", keys.F8},
+	{"F9", keys.F9}, {"F10", keys.F10}, {"F11", keys.F11}, {"F12", keys.F12},
+	{"NumLock", keys.NumLock},
+	{"ScrollLock", keys.ScrollLock},
+}
+
+// KeyChord returns the chord for a key event.  Characters are folded to lower
+// case, since Shift is part of the chord.
+func KeyChord(key Key) Chord {
+	chord := Chord{
+		VK:    key.VK,
+		Alt:   key.LAlt || key.RAlt,
+		Ctrl:  key.LCtrl || key.RCtrl,
+		Shift: key.Shift,
+	}
+	if key.VK == keys.Char {
+		chord.C = lower(key.C)
+	}
+	return chord
+}
+
+// shiftedKeys maps the punctuation typed with Shift on a US keyboard to the key
+// pressed, since key events carry the unshifted character.
+var shiftedKeys = map[byte]byte{
+	'!': '1', '@': '2', '#': '3', '$': '4', '%': '5',
+	'^': '6', '&': '7', '*': '8', '(': '9', ')': '0',
+	'_': '-', '+': '=', '{': '[', '}': ']', '|': '\\',
+	':': ';', '"': '\'', '<': ',', '>': '.', '?': '/', '~': '`',
+}
+
+// ParseChord parses a chord such as "Alt+Enter", "Shift+Up", "KP8" or "q".
+// Modifiers and key names are case insensitive, but a single upper case letter
+// is the same as Shift and the lower case letter.  Likewise shifted punctuation
+// is the same as Shift and the key it's typed with on a US keyboard, so "?" is
+// "Shift+/" and "!" is "Shift+1".
+func ParseChord(s string) (Chord, error) {
+	var chord Chord
+	parts := strings.Split(s, "+")
+	// "+" on its own, or as the last key of a chord, ends in an empty part.
+	if n := len(parts); n >= 2 && parts[n-1] == "" && parts[n-2] == "" {
+		parts = append(parts[:n-2], "+")
+	}
+
+	for _, mod := range parts[:len(parts)-1] {
+		switch strings.ToLower(strings.TrimSpace(mod)) {
+		case "alt":
+			chord.Alt = true
+		case "ctrl", "control":
+			chord.Ctrl = true
+		case "shift":
+			chord.Shift = true
+		default:
+			return Chord{}, fmt.Errorf("tcod: unknown modifier %q in chord %q", mod, s)
+		}
+	}
+
+	name := strings.TrimSpace(parts[len(parts)-1])
+	for _, k := range keyNames {
+		if strings.EqualFold(k.name, name) {
+			chord.VK = k.vk
+			return chord, nil
+		}
+	}
+	if len(name) == 1 && name[0] > ' ' && name[0] < 0x7f {
+		if base, ok := shiftedKeys[name[0]]; ok {
+			chord.Shift = true
+			if base >= '0' && base <= '9' {
+				chord.VK = keys.Zero + KeyCode(base-'0')
+			} else {
+				chord.VK, chord.C = keys.Char, base
+			}
+			return chord, nil
+		}
+		chord.VK, chord.C = keys.Char, lower(name[0])
+		if name[0] >= 'A' && name[0] <= 'Z' {
+			chord.Shift = true
+		}
+		return chord, nil
+	}
+	return Chord{}, fmt.Errorf("tcod: unknown key %q in chord %q", name, s)
+}
+
+// String formats the chord so it can be parsed by ParseChord, such as
+// "Ctrl+Shift+s".
+func (chord Chord) String() string {
+	var b strings.Builder
+	if chord.Ctrl {
+		b.WriteString("Ctrl+")
+	}
+	if chord.Alt {
+		b.WriteString("Alt+")
+	}
+	if chord.Shift {
+		b.WriteString("Shift+")
+	}
+	if chord.VK == keys.Char {
+		b.WriteByte(chord.C)
+		return b.String()
+	}
+	for _, k := range keyNames {
+		if k.vk == chord.VK {
+			b.WriteString(k.name)
+			return b.String()
+		}
+	}
+	fmt.Fprintf(&b, "Key%d", chord.VK)
+	return b.String()
+}
+
+// String returns a readable name for the key and its modifiers, such as
+// "Alt+Enter".
+func (key Key) String() string {
+	return KeyChord(key).String()
+}
+
+func lower(c byte) byte {
+	if c >= 'A' && c <= 'Z' {
+		return c + 'a' - 'A'
+	}
+	return c
+}
+
+// ConflictError is returned when a chord is bound to two actions in one mode.
+type ConflictError struct {
+	Mode     string
+	Chord    Chord
+	Action   string // the action being bound
+	Existing string // the action the chord is already bound to
+}
+
+func (err *ConflictError) Error() string {
+	return fmt.Sprintf("tcod: %s is bound to both %q and %q in mode %q",
+		err.Chord, err.Existing, err.Action, err.Mode)
+}
+
+// KeyMode is a set of key bindings that are active together, such as the keys
+// for moving around the map or for picking a target.
+type KeyMode struct {
+	Name     string
+	actions  []string // in the order they were first bound
+	bindings map[string][]Chord
+	chords   map[Chord]string
+}
+
+func newKeyMode(name string) *KeyMode {
+	return &KeyMode{
+		Name:     name,
+		bindings: make(map[string][]Chord),
+		chords:   make(map[Chord]string),
+	}
+}
+
+// Bind adds chords, such as "Alt+Enter", to action.  If a chord is already bound
+// to a different action, Bind returns a *ConflictError and leaves the chord bound
+// as it was.
+func (mode *KeyMode) Bind(action string, chords ...string) error {
+	for _, s := range chords {
+		chord, err := ParseChord(s)
+		if err != nil {
+			return err
+		}
+		if err := mode.BindChord(action, chord); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// BindChord adds chord to action, as with Bind.
+func (mode *KeyMode) BindChord(action string, chord Chord) error {
+	if existing, ok := mode.chords[chord]; ok {
+		if existing == action {
+			return nil
+		}
+		return &ConflictError{mode.Name, chord, action, existing}
+	}
+	if _, ok := mode.bindings[action]; !ok {
+		mode.actions = append(mode.actions, action)
+	}
+	mode.bindings[action] = append(mode.bindings[action], chord)
+	mode.chords[chord] = action
+	return nil
+}
+
+// Unbind removes every chord bound to action.  The action stays in the mode, so
+// it can still be loaded from a .cfg file.
+func (mode *KeyMode) Unbind(action string) {
+	if len(mode.bindings[action]) == 0 {
+		return
+	}
+	mode.bindings[action] = nil
+
+	// The package's delete function hides the builtin, so the chord lookup is
+	// rebuilt without the action.
+	chords := make(map[Chord]string, len(mode.chords))
+	for chord, a := range mode.chords {
+		if a != action {
+			chords[chord] = a
+		}
+	}
+	mode.chords = chords
+}
+
+// Chords returns the chords bound to action.
+func (mode *KeyMode) Chords(action string) []Chord {
+	return append([]Chord(nil), mode.bindings[action]...)
+}
+
+// Actions returns the actions in the mode, in the order they were first bound.
+func (mode *KeyMode) Actions() []string {
+	return append([]string(nil), mode.actions...)
+}
+
+// Action returns the action bound to key, if any.
+func (mode *KeyMode) Action(key Key) (string, bool) {
+	action, ok := mode.chords[KeyChord(key)]
+	return action, ok
+}
+
+// Keymap holds named modes of key bindings.  Modes are stacked: a key is looked
+// up in the top mode first, then in the modes below it, so a targeting mode can be
+// pushed over a movement mode and only override the keys it needs.
+type Keymap struct {
+	modes map[string]*KeyMode
+	order []string // mode names, in the order they were created
+	stack []*KeyMode
+}
+
+// NewKeymap creates an empty keymap.
+func NewKeymap() *Keymap {
+	return &Keymap{modes: make(map[string]*KeyMode)}
+}
+
+// Mode returns the named mode, creating it if needed.  Mode and action names are
+// saved as .cfg identifiers, so they should be made of letters, digits and
+// underscores.
+func (keymap *Keymap) Mode(name string) *KeyMode {
+	if mode, ok := keymap.modes[name]; ok {
+		return mode
+	}
+	mode := newKeyMode(name)
+	keymap.modes[name] = mode
+	keymap.order = append(keymap.order, name)
+	return mode
+}
+
+// SetMode makes the named mode the only active mode.
+func (keymap *Keymap) SetMode(name string) {
+	keymap.stack = append(keymap.stack[:0], keymap.Mode(name))
+}
+
+// PushMode activates the named mode on top of the active modes.
+func (keymap *Keymap) PushMode(name string) {
+	keymap.stack = append(keymap.stack, keymap.Mode(name))
+}
+
+// PopMode deactivates the top mode, returning to the mode below it.
+func (keymap *Keymap) PopMode() {
+	if len(keymap.stack) > 0 {
+		keymap.stack = keymap.stack[:len(keymap.stack)-1]
+	}
+}
+
+// CurrentMode returns the name of the top active mode, or "" if none are active.
+func (keymap *Keymap) CurrentMode() string {
+	if len(keymap.stack) == 0 {
+		return ""
+	}
+	return keymap.stack[len(keymap.stack)-1].Name
+}
+
+// Action returns the action bound to key in the active modes, if any.
+func (keymap *Keymap) Action(key Key) (string, bool) {
+	for i := len(keymap.stack) - 1; i >= 0; i-- {
+		if action, ok := keymap.stack[i].Action(key); ok {
+			return action, true
+		}
+	}
+	return "", false
+}
+
+// Load replaces bindings with those in a libtcod .cfg file, such as one written
+// by Save.  Each mode is a structure, and each action a list of chords:
+//
+//	movement {
+//		north = [ "Up", "KP8", "k" ]
+//	}
+//
+// Only the modes and actions already in the keymap are recognised, so bind the
+// defaults before loading a player's customisations over them.  Unknown modes or
+// actions, chords that don't parse and chords bound to two actions are reported
+// as errors, and leave the keymap unchanged.
+func (keymap *Keymap) Load(filename string) error {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+	loaded, err := keymap.parse(filename, data)
+	if err != nil {
+		return err
+	}
+
+	// Check for conflicts before changing anything.  Everything being replaced
+	// is unbound first, so keys can be swapped between actions.
+	for mode, actions := range loaded {
+		chords := make(map[Chord]string, len(mode.chords))
+		for chord, action := range mode.chords {
+			if _, ok := actions[action]; !ok {
+				chords[chord] = action
+			}
+		}
+		for _, action := range mode.actions {
+			for _, chord := range actions[action] {
+				if existing, ok := chords[chord]; ok && existing != action {
+					return &ConflictError{mode.Name, chord, action, existing}
+				}
+				chords[chord] = action
+			}
+		}
+	}
+
+	for mode, actions := range loaded {
+		for action := range actions {
+			mode.Unbind(action)
+		}
+		for _, action := range mode.actions {
+			for _, chord := range actions[action] {
+				mode.BindChord(action, chord)
+			}
+		}
+	}
+	return nil
+}
+
+// parse reads the chords for each mode and action in a keymap file.
+func (keymap *Keymap) parse(source string, data []byte) (map[*KeyMode]map[string][]Chord, error) {
+	p := newCfgScanner(source, data)
+	loaded := make(map[*KeyMode]map[string][]Chord)
+	for {
+		name, err := p.next()
+		if err != nil {
+			return nil, err
+		}
+		if name == "" && !p.quoted {
+			return loaded, nil
+		}
+		mode, ok := keymap.modes[name]
+		if !ok || p.quoted {
+			return nil, p.errorf("unknown mode %q", name)
+		}
+		if loaded[mode] != nil {
+			return nil, p.errorf("mode %q appears twice", name)
+		}
+		if err := p.expect("{"); err != nil {
+			return nil, err
+		}
+
+		actions := make(map[string][]Chord)
+		for {
+			action, err := p.next()
+			if err != nil {
+				return nil, err
+			}
+			if action == "}" && !p.quoted {
+				break
+			}
+			if action == "" && !p.quoted {
+				return nil, p.errorf("mode %q is missing its closing }", name)
+			}
+			if _, ok := mode.bindings[action]; !ok || p.quoted {
+				return nil, p.errorf("mode %q has no action %q", name, action)
+			}
+			if _, ok := actions[action]; ok {
+				return nil, p.errorf("mode %q binds %s twice", name, action)
+			}
+			chords, err := parseChordList(p)
+			if err != nil {
+				return nil, err
+			}
+			actions[action] = chords
+		}
+		loaded[mode] = actions
+	}
+}
+
+// parseChordList parses "= [ ... ]", a list of quoted chords.
+func parseChordList(p *cfgScanner) ([]Chord, error) {
+	if err := p.expect("="); err != nil {
+		return nil, err
+	}
+	if err := p.expect("["); err != nil {
+		return nil, err
+	}
+
+	chords := []Chord{}
+	for {
+		s, err := p.next()
+		if err != nil {
+			return nil, err
+		}
+		if s == "]" && !p.quoted && len(chords) == 0 {
+			return chords, nil
+		}
+		if !p.quoted {
+			return nil, p.errorf("expected a quoted chord, found %q", s)
+		}
+		chord, err := ParseChord(s)
+		if err != nil {
+			return nil, p.errorf("%s", strings.TrimPrefix(err.Error(), "tcod: "))
+		}
+		chords = append(chords, chord)
+
+		if s, err = p.next(); err != nil {
+			return nil, err
+		}
+		if s == "]" && !p.quoted {
+			return chords, nil
+		}
+		if s != "," || p.quoted {
+			return nil, p.errorf("expected \",\" or \"]\", found %q", s)
+		}
+	}
+}
+
+// Save writes the keymap to a libtcod .cfg file that Load can read.
+func (keymap *Keymap) Save(filename string) error {
+	f, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+
+	w := bufio.NewWriter(f)
+	for _, name := range keymap.order {
+		mode := keymap.modes[name]
+		fmt.Fprintf(w, "%s {\n", name)
+		for _, action := range mode.actions {
+			if len(mode.bindings[action]) == 0 {
+				continue
+			}
+			quoted := make([]string, len(mode.bindings[action]))
+			for i, chord := range mode.bindings[action] {
+				quoted[i] = quoteCfg(chord.String())
+			}
+			fmt.Fprintf(w, "\t%s = [ %s ]\n", action, strings.Join(quoted, ", "))
+		}
+		fmt.Fprintf(w, "}\n")
+	}
+
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// quoteCfg quotes s as a .cfg string.
+func quoteCfg(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
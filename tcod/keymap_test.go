@@ -0,0 +1,266 @@
+package tcod
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/sbowman/tcod/tcod/keys"
+)
+
+func TestParseChord(t *testing.T) {
+	tests := []struct {
+		s     string
+		chord Chord
+	}{
+		{"q", Chord{VK: keys.Char, C: 'q'}},
+		{"Q", Chord{VK: keys.Char, C: 'q', Shift: true}},
+		{"Alt+Enter", Chord{VK: keys.Enter, Alt: true}},
+		{"ctrl+shift+s", Chord{VK: keys.Char, C: 's', Ctrl: true, Shift: true}},
+		{"KP8", Chord{VK: keys.KP8}},
+		{"?", Chord{VK: keys.Char, C: '/', Shift: true}},
+		{"Shift+/", Chord{VK: keys.Char, C: '/', Shift: true}},
+		{"Ctrl+>", Chord{VK: keys.Char, C: '.', Ctrl: true, Shift: true}},
+		{"!", Chord{VK: keys.One, Shift: true}},
+		{")", Chord{VK: keys.Zero, Shift: true}},
+		{"+", Chord{VK: keys.Char, C: '=', Shift: true}},
+		{"Alt++", Chord{VK: keys.Char, C: '=', Alt: true, Shift: true}},
+		{"-", Chord{VK: keys.Char, C: '-'}},
+	}
+	for _, test := range tests {
+		chord, err := ParseChord(test.s)
+		if err != nil {
+			t.Errorf("ParseChord(%q): %v", test.s, err)
+			continue
+		}
+		if chord != test.chord {
+			t.Errorf("ParseChord(%q) = %+v, want %+v", test.s, chord, test.chord)
+		}
+	}
+
+	// A shifted character arrives as the unshifted key with Shift held.
+	chord, _ := ParseChord("?")
+	if event := KeyChord(Key{VK: keys.Char, C: '/', Pressed: true, Shift: true}); event != chord {
+		t.Errorf("Shift+/ key event gives %v, want %v", event, chord)
+	}
+
+	for _, s := range []string{"Hyper+q", "Banana", "Ctrl+"} {
+		if _, err := ParseChord(s); err == nil {
+			t.Errorf("ParseChord(%q) succeeded", s)
+		}
+	}
+}
+
+// newTestKeymap returns a keymap with movement and targeting modes.
+func newTestKeymap(t *testing.T) *Keymap {
+	t.Helper()
+	keymap := NewKeymap()
+	movement := keymap.Mode("movement")
+	for _, binding := range [][]string{
+		{"north", "Up", "KP8", "k"},
+		{"south", "Down", "KP2", "j"},
+		{"help", "?"},
+	} {
+		if err := movement.Bind(binding[0], binding[1:]...); err != nil {
+			t.Fatal(err)
+		}
+	}
+	targeting := keymap.Mode("targeting")
+	if err := targeting.Bind("fire", "f", "Enter"); err != nil {
+		t.Fatal(err)
+	}
+	if err := targeting.Bind("cancel", "Escape", "Ctrl+Alt+q"); err != nil {
+		t.Fatal(err)
+	}
+	return keymap
+}
+
+// bindings returns every chord in the keymap, as strings, keyed by mode and
+// action.
+func bindings(keymap *Keymap) map[string][]string {
+	result := make(map[string][]string)
+	for _, name := range keymap.order {
+		mode := keymap.Mode(name)
+		for _, action := range mode.Actions() {
+			for _, chord := range mode.Chords(action) {
+				result[name+"."+action] = append(result[name+"."+action], chord.String())
+			}
+		}
+	}
+	return result
+}
+
+func writeKeymapFile(t *testing.T, contents string) string {
+	t.Helper()
+	filename := filepath.Join(t.TempDir(), "keys.cfg")
+	if err := os.WriteFile(filename, []byte(contents), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return filename
+}
+
+func TestKeymapSaveLoad(t *testing.T) {
+	saved := newTestKeymap(t)
+	saved.Mode("movement").Unbind("help")
+	if err := saved.Mode("targeting").Bind("fire", "Shift+F5"); err != nil {
+		t.Fatal(err)
+	}
+	filename := filepath.Join(t.TempDir(), "keys.cfg")
+	if err := saved.Save(filename); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded := newTestKeymap(t)
+	if err := loaded.Load(filename); err != nil {
+		t.Fatal(err)
+	}
+	// help isn't saved without any chords, so it keeps its default.
+	want := bindings(saved)
+	want["movement.help"] = []string{"Shift+/"}
+	if got := bindings(loaded); !reflect.DeepEqual(got, want) {
+		t.Errorf("loaded %v, want %v", got, want)
+	}
+}
+
+func TestKeymapLoadSwap(t *testing.T) {
+	keymap := newTestKeymap(t)
+	filename := writeKeymapFile(t, `
+		// k and j swap places, which only works if both are replaced at once.
+		movement {
+			north = [ "j" ]
+			south = [ "k", "Down" ]
+		}
+		targeting {
+			cancel = [ ]
+		}`)
+	if err := keymap.Load(filename); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string][]string{
+		"movement.north":   {"j"},
+		"movement.south":   {"k", "Down"},
+		"movement.help":    {"Shift+/"},
+		"targeting.fire":   {"f", "Enter"},
+		"targeting.cancel": nil,
+	}
+	got := bindings(keymap)
+	for key, chords := range want {
+		if !reflect.DeepEqual(got[key], chords) {
+			t.Errorf("%s is bound to %v, want %v", key, got[key], chords)
+		}
+	}
+}
+
+func TestKeymapLoadErrors(t *testing.T) {
+	tests := []struct {
+		name, contents, err string
+	}{
+		{"unknown mode", `inventory { drop = [ "d" ] }`, `unknown mode "inventory"`},
+		{"unknown action", `movement { west = [ "h" ] }`, `mode "movement" has no action "west"`},
+		{"bad chord", `movement { north = [ "Hyper+k" ] }`, `keys.cfg:1: unknown modifier`},
+		{"bad key", "movement {\n north = [ \"Banana\" ]\n}", `keys.cfg:2: unknown key`},
+		{"not a list", `movement { north = "k" }`, `expected "["`},
+		{"unquoted chord", `movement { north = [ k ] }`, `expected a quoted chord`},
+		{"missing comma", `movement { north = [ "k" "j" ] }`, `expected "," or "]"`},
+		{"unclosed mode", `movement { north = [ "k" ]`, `missing its closing }`},
+		{"unterminated string", `movement { north = [ "k ] }`, `unterminated string`},
+		{"repeated action", `movement { north = [ "k" ] north = [ "j" ] }`, `binds north twice`},
+		{"repeated mode", `movement { } movement { }`, `mode "movement" appears twice`},
+	}
+	for _, test := range tests {
+		keymap := newTestKeymap(t)
+		want := bindings(keymap)
+		err := keymap.Load(writeKeymapFile(t, test.contents))
+		if err == nil {
+			t.Errorf("%s: Load succeeded", test.name)
+			continue
+		}
+		if !strings.Contains(err.Error(), test.err) {
+			t.Errorf("%s: error is %q, want one containing %q", test.name, err, test.err)
+		}
+		if got := bindings(keymap); !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: the failed load changed the keymap to %v", test.name, got)
+		}
+	}
+
+	if err := NewKeymap().Load(filepath.Join(t.TempDir(), "missing.cfg")); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("loading a missing file gave %v", err)
+	}
+}
+
+func TestKeymapConflicts(t *testing.T) {
+	keymap := newTestKeymap(t)
+	err := keymap.Mode("movement").Bind("south", "Shift+/")
+	var conflict *ConflictError
+	if !errors.As(err, &conflict) {
+		t.Fatalf("binding a chord twice gave %v", err)
+	}
+	want := ConflictError{"movement", Chord{VK: keys.Char, C: '/', Shift: true}, "south", "help"}
+	if *conflict != want {
+		t.Errorf("conflict is %+v, want %+v", *conflict, want)
+	}
+	if action, _ := keymap.Mode("movement").Action(Key{VK: keys.Char, C: '/', Shift: true}); action != "help" {
+		t.Errorf("the conflicting chord is bound to %q, want help", action)
+	}
+
+	// The same chord may be bound in different modes.
+	if err := keymap.Mode("targeting").Bind("cancel", "k"); err != nil {
+		t.Errorf("binding k in another mode: %v", err)
+	}
+
+	// Conflicts in a loaded file, either within it or with bindings it doesn't
+	// replace, leave the keymap as it was.
+	for _, contents := range []string{
+		`movement { north = [ "x" ] south = [ "x" ] }`,
+		`movement { north = [ "Shift+/" ] }`,
+	} {
+		want := bindings(keymap)
+		err := keymap.Load(writeKeymapFile(t, contents))
+		if !errors.As(err, &conflict) {
+			t.Errorf("loading %s gave %v", contents, err)
+		}
+		if got := bindings(keymap); !reflect.DeepEqual(got, want) {
+			t.Errorf("loading %s changed the keymap to %v", contents, got)
+		}
+	}
+}
+
+func TestKeymapModes(t *testing.T) {
+	keymap := newTestKeymap(t)
+	k := Key{VK: keys.Char, C: 'k', Pressed: true}
+	enter := Key{VK: keys.Enter, Pressed: true}
+	if err := keymap.Mode("targeting").Bind("north", "k"); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		change      func()
+		mode        string
+		kAction     string
+		enterAction string
+	}{
+		{func() {}, "", "", ""},
+		{func() { keymap.SetMode("movement") }, "movement", "north", ""},
+		{func() { keymap.PushMode("targeting") }, "targeting", "north", "fire"},
+		{func() { keymap.Mode("targeting").Unbind("north") }, "targeting", "north", "fire"},
+		{func() { keymap.PopMode() }, "movement", "north", ""},
+		{func() { keymap.SetMode("targeting") }, "targeting", "", "fire"},
+		{func() { keymap.PopMode() }, "", "", ""},
+		{func() { keymap.PopMode() }, "", "", ""},
+	}
+	for i, test := range tests {
+		test.change()
+		if mode := keymap.CurrentMode(); mode != test.mode {
+			t.Errorf("step %d: current mode is %q, want %q", i, mode, test.mode)
+		}
+		if action, ok := keymap.Action(k); action != test.kAction || ok != (test.kAction != "") {
+			t.Errorf("step %d: k is %q, %v, want %q", i, action, ok, test.kAction)
+		}
+		if action, _ := keymap.Action(enter); action != test.enterAction {
+			t.Errorf("step %d: Enter is %q, want %q", i, action, test.enterAction)
+		}
+	}
+}
@@ -0,0 +1,221 @@
+package tcod
+
+import (
+	"math"
+)
+
+//
+// Coloured lighting
+//
+
+// Falloff returns how bright a light is at a fraction of its radius, from 1 at
+// the light to 0 at the edge.
+type Falloff func(distance float32) float32
+
+var (
+	// FalloffNone lights everything in the radius at full strength.
+	FalloffNone Falloff = func(distance float32) float32 {
+		return 1
+	}
+
+	// FalloffLinear fades evenly out to the edge of the radius.
+	FalloffLinear Falloff = func(distance float32) float32 {
+		return 1 - distance
+	}
+
+	// FalloffQuadratic stays bright near the light and fades quickly at the
+	// edge.
+	FalloffQuadratic Falloff = func(distance float32) float32 {
+		return 1 - distance*distance
+	}
+
+	// FalloffInverseSquare fades quickly near the light, like a torch in the
+	// dark.
+	FalloffInverseSquare Falloff = func(distance float32) float32 {
+		d := 1 + 4*distance
+		return (1/(d*d) - 1.0/25) * 25 / 24
+	}
+)
+
+// Light is a point light.  Its colour is scaled by Intensity and by the Falloff
+// at each cell's distance, and cells out of the light's field of view are left
+// dark.
+//
+// If Noise is set, the light flickers: its brightness is scaled by up to Flicker,
+// from 0 to 1, following the noise as time passes at FlickerSpeed.  Noise should
+// have a single dimension, and may be shared between lights.
+type Light struct {
+	X, Y      int
+	Radius    int
+	Color     Color
+	Intensity float32
+	Falloff   Falloff // FalloffLinear if nil
+
+	Noise        *Noise
+	Flicker      float32
+	FlickerSpeed float32
+}
+
+// brightness returns the light's intensity at time t, after flickering.
+func (light *Light) brightness(t float32) float32 {
+	if light.Noise == nil || light.Flicker == 0 {
+		return light.Intensity
+	}
+	// Offset each light by its position, so lights sharing the noise don't
+	// flicker together.
+	f := light.Noise.Get(FloatArray{t*light.FlickerSpeed + float32(light.X*7+light.Y*13)})
+	return light.Intensity * (1 - light.Flicker*(f+1)*0.5)
+}
+
+// LightMap accumulates the light falling on each cell of a map, blending the
+// colours of overlapping lights by adding them together.  Compute it each frame
+// and Apply it to the console after drawing the map.
+type LightMap struct {
+	// Ambient light reaches every cell, lit or not.
+	Ambient Color
+
+	// Algorithm is the field of view used to cast light, FOVBasic by default.
+	Algorithm FovAlgorithm
+
+	w, h    int
+	fov     *Map
+	r, g, b []float32
+}
+
+// NewLightMap creates a w x h light map with no ambient light.
+func NewLightMap(w, h int) *LightMap {
+	return &LightMap{
+		Algorithm: FOVBasic,
+		w:         w,
+		h:         h,
+		fov:       NewMap(w, h),
+		r:         make([]float32, w*h),
+		g:         make([]float32, w*h),
+		b:         make([]float32, w*h),
+	}
+}
+
+func (lm *LightMap) GetWidth() int {
+	return lm.w
+}
+
+func (lm *LightMap) GetHeight() int {
+	return lm.h
+}
+
+// Compute casts lights across m, which should be the same size as the light map,
+// at time t, which drives any flickering.  The field of view already computed on
+// m is left alone.
+func (lm *LightMap) Compute(m *Map, lights []Light, t float32) {
+	m.Copy(*lm.fov)
+
+	ambient := [3]float32{float32(lm.Ambient.R), float32(lm.Ambient.G), float32(lm.Ambient.B)}
+	for i := range lm.r {
+		lm.r[i], lm.g[i], lm.b[i] = ambient[0], ambient[1], ambient[2]
+	}
+
+	for i := range lights {
+		lm.cast(&lights[i], t)
+	}
+}
+
+func (lm *LightMap) cast(light *Light, t float32) {
+	if light.Radius <= 0 || light.X < 0 || light.Y < 0 || light.X >= lm.w || light.Y >= lm.h {
+		return
+	}
+	brightness := light.brightness(t)
+	if brightness <= 0 {
+		return
+	}
+	falloff := light.Falloff
+	if falloff == nil {
+		falloff = FalloffLinear
+	}
+
+	lm.fov.ComputeFov(light.X, light.Y, light.Radius, true, lm.Algorithm)
+
+	r := float32(light.Color.R) * brightness
+	g := float32(light.Color.G) * brightness
+	b := float32(light.Color.B) * brightness
+	radius := float32(light.Radius)
+
+	minx, maxx := max(0, light.X-light.Radius), min(lm.w-1, light.X+light.Radius)
+	miny, maxy := max(0, light.Y-light.Radius), min(lm.h-1, light.Y+light.Radius)
+	for y := miny; y <= maxy; y++ {
+		dy := float32(y - light.Y)
+		for x := minx; x <= maxx; x++ {
+			dx := float32(x - light.X)
+			distance := float32(math.Sqrt(float64(dx*dx+dy*dy))) / radius
+			if distance > 1 || !lm.fov.IsInFov(x, y) {
+				continue
+			}
+			f := falloff(distance)
+			if f <= 0 {
+				continue
+			}
+			i := y*lm.w + x
+			lm.r[i] += r * f
+			lm.g[i] += g * f
+			lm.b[i] += b * f
+		}
+	}
+}
+
+// GetLight returns the colour of the light on a cell, clamped to white.
+func (lm *LightMap) GetLight(x, y int) Color {
+	if x < 0 || y < 0 || x >= lm.w || y >= lm.h {
+		return Color{}
+	}
+	i := y*lm.w + x
+	return Color{clampChannel(lm.r[i]), clampChannel(lm.g[i]), clampChannel(lm.b[i])}
+}
+
+// Apply lights the console by multiplying the foreground and background colours
+// of each cell by the light on it.  Cells beyond the light map are left alone.
+func (lm *LightMap) Apply(console IConsole) {
+	w, h := min(lm.w, console.GetWidth()), min(lm.h, console.GetHeight())
+
+	// Consoles with tiles in memory are lit in place, without a cgo call per
+	// cell.
+	var tiles []Tile
+	switch c := console.(type) {
+	case *Console:
+		tiles = c.Tiles()
+	case *HeadlessConsole:
+		tiles = c.Tiles()
+	}
+	if tiles != nil {
+		cw := console.GetWidth()
+		for y := 0; y < h; y++ {
+			for x := 0; x < w; x++ {
+				i, tile := y*lm.w+x, &tiles[y*cw+x]
+				r, g, b := lm.r[i]/255, lm.g[i]/255, lm.b[i]/255
+				tile.Fg, tile.Bg = lightColor(tile.Fg, r, g, b), lightColor(tile.Bg, r, g, b)
+			}
+		}
+		return
+	}
+
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			i := y*lm.w + x
+			r, g, b := lm.r[i]/255, lm.g[i]/255, lm.b[i]/255
+			console.SetCharForeground(x, y, lightColor(console.GetCharForeground(x, y), r, g, b))
+			console.SetCharBackground(x, y, lightColor(console.GetCharBackground(x, y), r, g, b), BkgndSet)
+		}
+	}
+}
+
+func lightColor(c Color, r, g, b float32) Color {
+	return Color{clampChannel(float32(c.R) * r), clampChannel(float32(c.G) * g), clampChannel(float32(c.B) * b)}
+}
+
+func clampChannel(v float32) uint8 {
+	if v >= 255 {
+		return 255
+	}
+	if v <= 0 {
+		return 0
+	}
+	return uint8(v)
+}
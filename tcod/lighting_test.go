@@ -0,0 +1,117 @@
+package tcod
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+// perCellConsole hides a console's tiles, so LightMap.Apply has to go through
+// the IConsole methods.
+type perCellConsole struct {
+	IConsole
+}
+
+func newLitMap(w, h int) (*LightMap, *Map) {
+	m := NewMap(w, h)
+	m.Clear(true, true)
+	for x := 5; x < w-5; x += 7 {
+		m.SetProperties(x, h/2, false, false)
+	}
+	lm := NewLightMap(w, h)
+	lm.Ambient = Color{20, 20, 30}
+	lm.Compute(m, []Light{
+		{X: w / 3, Y: h / 3, Radius: 12, Color: Color{255, 160, 64}, Intensity: 1},
+		{X: w * 2 / 3, Y: h * 2 / 3, Radius: 20, Color: Color{64, 128, 255}, Intensity: 1.5, Falloff: FalloffQuadratic},
+	}, 0)
+	return lm, m
+}
+
+func fillConsole(console IConsole) {
+	for y := 0; y < console.GetHeight(); y++ {
+		for x := 0; x < console.GetWidth(); x++ {
+			console.SetChar(x, y, '.')
+			console.SetCharForeground(x, y, Color{uint8(x * 3), 200, uint8(y * 5)})
+			console.SetCharBackground(x, y, Color{40, uint8(x + y), 90}, BkgndSet)
+		}
+	}
+}
+
+func TestLightMapApply(t *testing.T) {
+	lm, _ := newLitMap(benchConsoleW, benchConsoleH)
+
+	// The console is wider than the light map, so the fast path has to skip the
+	// extra columns.
+	fast := NewHeadlessConsole(benchConsoleW+5, benchConsoleH)
+	slow := NewHeadlessConsole(benchConsoleW+5, benchConsoleH)
+	fillConsole(fast)
+	fillConsole(slow)
+	lm.Apply(fast)
+	lm.Apply(perCellConsole{slow})
+
+	if !reflect.DeepEqual(fast.Tiles(), slow.Tiles()) {
+		t.Error("lighting the tiles directly differs from lighting each cell")
+	}
+}
+
+func BenchmarkLightMapApply(b *testing.B) {
+	lm, _ := newLitMap(benchConsoleW, benchConsoleH)
+	console := NewConsole(benchConsoleW, benchConsoleH)
+	fillConsole(console)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		lm.Apply(console)
+	}
+}
+
+// BenchmarkLightMapApplyPerCell lights the same console a cgo call at a time,
+// for comparison.
+func BenchmarkLightMapApplyPerCell(b *testing.B) {
+	lm, _ := newLitMap(benchConsoleW, benchConsoleH)
+	console := NewConsole(benchConsoleW, benchConsoleH)
+	fillConsole(console)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		lm.Apply(perCellConsole{console})
+	}
+}
+
+// flickeringLights returns n lights scattered over a w x h map, sharing one
+// noise generator so they flicker as torches would.
+func flickeringLights(n, w, h int) []Light {
+	random := NewRandomFromSeed(1)
+	noise := NewNoise(1, random)
+	colors := []Color{{255, 160, 64}, {255, 200, 120}, {64, 128, 255}, {255, 64, 32}}
+	lights := make([]Light, n)
+	for i := range lights {
+		lights[i] = Light{
+			X:            random.GetInt(0, w-1),
+			Y:            random.GetInt(0, h-1),
+			Radius:       random.GetInt(6, 12),
+			Color:        colors[i%len(colors)],
+			Intensity:    1,
+			Noise:        noise,
+			Flicker:      0.3,
+			FlickerSpeed: 4,
+		}
+		if i%2 == 1 {
+			lights[i].Falloff = FalloffQuadratic
+		}
+	}
+	return lights
+}
+
+// BenchmarkLightMapCompute lights an 80x50 map with a level's worth of
+// flickering torches, advancing time each frame.
+func BenchmarkLightMapCompute(b *testing.B) {
+	for _, n := range []int{24, 48} {
+		b.Run(fmt.Sprintf("%dLights", n), func(b *testing.B) {
+			lm, m := newLitMap(benchConsoleW, benchConsoleH)
+			lights := flickeringLights(n, benchConsoleW, benchConsoleH)
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				lm.Compute(m, lights, float32(i)/60)
+			}
+		})
+	}
+}
@@ -0,0 +1,198 @@
+package tcod
+
+import (
+	"errors"
+	"fmt"
+)
+
+//
+// Map memory
+//
+
+// MemoryCell is how a cell looked the last time it was in view.
+type MemoryCell struct {
+	Ch         rune
+	Fore, Back Color
+}
+
+// MapMemory remembers which cells of a map have been explored, and what they
+// looked like when last seen.  Update it after drawing the live view each turn,
+// and Render it to show remembered cells that are out of view.
+type MapMemory struct {
+	// Darken and Desaturate control how remembered cells are dimmed when
+	// rendered, from 0 for unchanged to 1 for black or grey.
+	Darken     float32
+	Desaturate float32
+
+	w, h     int
+	explored []bool
+	cells    []MemoryCell
+}
+
+// NewMapMemory creates a w x h memory with nothing explored.
+func NewMapMemory(w, h int) *MapMemory {
+	return &MapMemory{
+		Darken:     0.5,
+		Desaturate: 0.7,
+		w:          w,
+		h:          h,
+		explored:   make([]bool, w*h),
+		cells:      make([]MemoryCell, w*h),
+	}
+}
+
+func (mm *MapMemory) GetWidth() int {
+	return mm.w
+}
+
+func (mm *MapMemory) GetHeight() int {
+	return mm.h
+}
+
+func (mm *MapMemory) inBounds(x, y int) bool {
+	return x >= 0 && y >= 0 && x < mm.w && y < mm.h
+}
+
+// Clear forgets everything.
+func (mm *MapMemory) Clear() {
+	for i := range mm.explored {
+		mm.explored[i] = false
+		mm.cells[i] = MemoryCell{}
+	}
+}
+
+// IsExplored returns true if the cell has ever been in view.
+func (mm *MapMemory) IsExplored(x, y int) bool {
+	return mm.inBounds(x, y) && mm.explored[y*mm.w+x]
+}
+
+// SetExplored marks a cell as explored or not, such as for a magic map.
+func (mm *MapMemory) SetExplored(x, y int, explored bool) {
+	if mm.inBounds(x, y) {
+		mm.explored[y*mm.w+x] = explored
+	}
+}
+
+// GetCell returns how the cell looked when last seen, and false if it was never
+// explored.
+func (mm *MapMemory) GetCell(x, y int) (MemoryCell, bool) {
+	if !mm.IsExplored(x, y) {
+		return MemoryCell{}, false
+	}
+	return mm.cells[y*mm.w+x], true
+}
+
+// SetCell remembers how a cell looked, and marks it explored.
+func (mm *MapMemory) SetCell(x, y int, cell MemoryCell) {
+	if mm.inBounds(x, y) {
+		mm.explored[y*mm.w+x] = true
+		mm.cells[y*mm.w+x] = cell
+	}
+}
+
+// Update marks the cells in m's field of view as explored, and remembers how they
+// look on the console, which should have the live view drawn on it.  Map cells
+// are at the same coordinates on the console.
+func (mm *MapMemory) Update(m *Map, console IConsole) {
+	w := min(min(mm.w, m.GetWidth()), console.GetWidth())
+	h := min(min(mm.h, m.GetHeight()), console.GetHeight())
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			if m.IsInFov(x, y) {
+				mm.SetCell(x, y, MemoryCell{
+					Ch:   console.GetChar(x, y),
+					Fore: console.GetCharForeground(x, y),
+					Back: console.GetCharBackground(x, y),
+				})
+			}
+		}
+	}
+}
+
+// Render draws the remembered cells onto the console, dimmed.  Cells in m's field
+// of view are skipped, so the live view can be drawn before or after; m may be
+// nil to draw every explored cell.
+func (mm *MapMemory) Render(m *Map, console IConsole) {
+	w, h := min(mm.w, console.GetWidth()), min(mm.h, console.GetHeight())
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			cell, ok := mm.GetCell(x, y)
+			if !ok || (m != nil && m.IsInFov(x, y)) {
+				continue
+			}
+			console.PutCharEx(x, y, cell.Ch, mm.dim(cell.Fore), mm.dim(cell.Back))
+		}
+	}
+}
+
+func (mm *MapMemory) dim(c Color) Color {
+	if mm.Desaturate > 0 {
+		grey := uint8((int(c.R)*30 + int(c.G)*59 + int(c.B)*11) / 100)
+		c = c.Lerp(Color{grey, grey, grey}, mm.Desaturate)
+	}
+	if mm.Darken > 0 {
+		c = c.Darken(mm.Darken)
+	}
+	return c
+}
+
+// Save writes the memory to the zip.
+func (mm *MapMemory) Save(zip *Zip) {
+	zip.PutInt(mm.w)
+	zip.PutInt(mm.h)
+	for i, explored := range mm.explored {
+		if !explored {
+			zip.PutChar(0)
+			continue
+		}
+		zip.PutChar(1)
+		zip.PutInt(int(mm.cells[i].Ch))
+		zip.PutColor(mm.cells[i].Fore)
+		zip.PutColor(mm.cells[i].Back)
+	}
+}
+
+// memoryCellBytes is the size of an explored cell in a zip, after its flag: the
+// glyph and two colors.
+const memoryCellBytes = 4 + 3 + 3
+
+// Load replaces the memory with one read from the zip, resizing it to match.  If
+// the zip is truncated or doesn't hold a map memory, Load returns an error and
+// leaves the memory as it was.
+func (mm *MapMemory) Load(zip *Zip) error {
+	if zip.GetRemainingBytes() < 8 {
+		return errors.New("tcod: map memory is truncated")
+	}
+	w, h := zip.GetInt(), zip.GetInt()
+	// Every cell takes at least a byte, so the size can't be more than the bytes
+	// left.  It's worked out in 64 bits so a huge size can't wrap around.
+	if w < 0 || h < 0 || uint64(w)*uint64(h) > uint64(zip.GetRemainingBytes()) {
+		return fmt.Errorf("tcod: invalid map memory size %dx%d", w, h)
+	}
+
+	explored := make([]bool, w*h)
+	cells := make([]MemoryCell, w*h)
+	for i := range explored {
+		if zip.GetRemainingBytes() < 1 {
+			return errors.New("tcod: map memory is truncated")
+		}
+		switch flag := zip.GetChar(); flag {
+		case 0:
+			continue
+		case 1:
+		default:
+			return fmt.Errorf("tcod: invalid map memory cell flag %d", flag)
+		}
+		if zip.GetRemainingBytes() < memoryCellBytes {
+			return errors.New("tcod: map memory is truncated")
+		}
+		explored[i] = true
+		cells[i].Ch = rune(zip.GetInt())
+		cells[i].Fore = zip.GetColor()
+		cells[i].Back = zip.GetColor()
+	}
+
+	mm.w, mm.h = w, h
+	mm.explored, mm.cells = explored, cells
+	return nil
+}
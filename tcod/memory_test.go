@@ -0,0 +1,97 @@
+package tcod
+
+import (
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// reloadZip saves zip to a file and loads it into a new zip for reading.
+func reloadZip(t *testing.T, zip *Zip) *Zip {
+	t.Helper()
+	filename := filepath.Join(t.TempDir(), "memory.sav")
+	zip.SaveToFile(filename)
+	loaded := NewZip()
+	loaded.LoadFromFile(filename)
+	return loaded
+}
+
+func newTestMemory() *MapMemory {
+	mm := NewMapMemory(4, 3)
+	mm.SetCell(0, 0, MemoryCell{'#', Color{200, 180, 160}, Color{10, 20, 30}})
+	mm.SetCell(3, 1, MemoryCell{'@', White, Black})
+	mm.SetCell(2, 2, MemoryCell{0x2550, Color{1, 2, 3}, Color{4, 5, 6}})
+	mm.SetExplored(1, 2, true)
+	return mm
+}
+
+func TestMapMemorySaveLoad(t *testing.T) {
+	saved := newTestMemory()
+	zip := NewZip()
+	saved.Save(zip)
+	zip.PutInt(42)
+
+	loaded := NewMapMemory(1, 1)
+	loaded.SetCell(0, 0, MemoryCell{'x', White, White})
+	zip = reloadZip(t, zip)
+	if err := loaded.Load(zip); err != nil {
+		t.Fatal(err)
+	}
+	if v := zip.GetInt(); v != 42 {
+		t.Errorf("read %d after the memory, want 42", v)
+	}
+
+	if loaded.GetWidth() != 4 || loaded.GetHeight() != 3 {
+		t.Fatalf("loaded memory is %dx%d, want 4x3", loaded.GetWidth(), loaded.GetHeight())
+	}
+	if !reflect.DeepEqual(loaded.explored, saved.explored) || !reflect.DeepEqual(loaded.cells, saved.cells) {
+		t.Errorf("loaded %v %v, want %v %v", loaded.explored, loaded.cells, saved.explored, saved.cells)
+	}
+}
+
+func TestMapMemoryLoadErrors(t *testing.T) {
+	var full []byte
+	zip := NewZip()
+	newTestMemory().Save(zip)
+	zip = reloadZip(t, zip)
+	for zip.GetRemainingBytes() > 0 {
+		full = append(full, zip.GetChar())
+	}
+
+	// Zips are written four bytes at a time, padding the last, so the data is
+	// only cut at multiples of four; a cut inside the padding can't be seen.
+	tests := []struct {
+		name string
+		data []byte
+		err  string
+	}{
+		{"empty", nil, "truncated"},
+		{"no height", full[:4], "truncated"},
+		{"no cells", full[:8], "size 4x3"},
+		{"missing cells", full[:len(full)-12], "truncated"},
+		{"cut in an explored cell", full[:len(full)-8], "truncated"},
+		{"negative size", append([]byte{0xff, 0xff, 0xff, 0xff}, full[4:]...), "size"},
+		{"bad flag", append(append([]byte(nil), full[:8]...), append([]byte{7}, full[9:]...)...), "flag 7"},
+	}
+	for _, test := range tests {
+		zip := NewZip()
+		for _, c := range test.data {
+			zip.PutChar(c)
+		}
+
+		mm := NewMapMemory(2, 2)
+		mm.SetCell(1, 1, MemoryCell{'x', White, Black})
+		err := mm.Load(reloadZip(t, zip))
+		if err == nil {
+			t.Errorf("%s: Load succeeded", test.name)
+			continue
+		}
+		if !strings.Contains(err.Error(), test.err) {
+			t.Errorf("%s: error is %q, want one containing %q", test.name, err, test.err)
+		}
+		if cell, ok := mm.GetCell(1, 1); mm.GetWidth() != 2 || !ok || cell.Ch != 'x' {
+			t.Errorf("%s: the failed load changed the memory", test.name)
+		}
+	}
+}
@@ -0,0 +1,455 @@
+package tcod
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+//
+// Per-instance name generator
+//
+
+// nameGenMaxTries caps the number of words Generate throws away for having
+// triple letters or illegal strings before giving up on a set.
+const nameGenMaxTries = 1000
+
+// NameGenerator makes up names from syllable sets, like the Namegen functions,
+// but owns its sets and random number generator instead of sharing libtcod's
+// global ones.  It reads the same syllable set files, such as the .cfg files in
+// sample/data/namegen.
+type NameGenerator struct {
+	random *Random
+	sets   map[string]*nameSet
+}
+
+// nameSet is a parsed syllable set.  The syllable and phoneme lists are keyed by
+// the wildcard character rules use for them.
+type nameSet struct {
+	name    string
+	lists   map[byte][]string
+	rules   []string
+	illegal []string
+}
+
+// nameSetProperties maps each property of a set definition to its wildcard.
+// Rules and illegal strings are handled separately.
+var nameSetProperties = map[string]byte{
+	"syllablesPre":       'P',
+	"syllablesStart":     's',
+	"syllablesMiddle":    'm',
+	"syllablesEnd":       'e',
+	"syllablesPost":      'p',
+	"phonemesVocals":     'v',
+	"phonemesConsonants": 'c',
+}
+
+// NewNameGenerator returns a generator with no sets, taking its random numbers
+// from random.  If random is nil the generator gets a new one of its own.
+func NewNameGenerator(random *Random) *NameGenerator {
+	if random == nil {
+		random = NewRandom()
+	}
+	return &NameGenerator{
+		random: random,
+		sets:   make(map[string]*nameSet),
+	}
+}
+
+// ParseFile reads the syllable sets in a file.  A set with the same name as one
+// already read replaces it.
+func (gen *NameGenerator) ParseFile(filename string) error {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+	return gen.parse(filename, data)
+}
+
+// ParseString reads the syllable sets in s.
+func (gen *NameGenerator) ParseString(s string) error {
+	return gen.parse("string", []byte(s))
+}
+
+// Parse reads the syllable sets from r.
+func (gen *NameGenerator) Parse(r io.Reader) error {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	return gen.parse("reader", data)
+}
+
+// ParseFS reads the syllable sets in the files of fsys matching any of the
+// patterns, as understood by fs.Glob, so sets can be embedded in the program:
+//
+//	//go:embed data/namegen
+//	var namegen embed.FS
+//	...
+//	err := gen.ParseFS(namegen, "data/namegen/*.cfg")
+func (gen *NameGenerator) ParseFS(fsys fs.FS, patterns ...string) error {
+	for _, pattern := range patterns {
+		filenames, err := fs.Glob(fsys, pattern)
+		if err != nil {
+			return err
+		}
+		if len(filenames) == 0 {
+			return fmt.Errorf("tcod: pattern %q matches no files", pattern)
+		}
+		for _, filename := range filenames {
+			data, err := fs.ReadFile(fsys, filename)
+			if err != nil {
+				return err
+			}
+			if err := gen.parse(filename, data); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Sets returns the names of the generator's syllable sets, sorted.
+func (gen *NameGenerator) Sets() []string {
+	names := make([]string, 0, len(gen.sets))
+	for name := range gen.sets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// HasSet returns true if the generator has a syllable set called name.
+func (gen *NameGenerator) HasSet(name string) bool {
+	_, ok := gen.sets[name]
+	return ok
+}
+
+// Generate makes up a name using one of the set's rules, picked at random.
+func (gen *NameGenerator) Generate(set string) (string, error) {
+	s, ok := gen.sets[set]
+	if !ok {
+		return "", fmt.Errorf("tcod: unknown syllable set %q", set)
+	}
+
+	// Each rule is equally likely, but one with a "%NN" prefix only has an NN
+	// percent chance of being kept once it's picked.
+	for {
+		rule := s.rules[gen.random.GetInt(0, len(s.rules)-1)]
+		chance, _ := ruleChance(rule)
+		if gen.random.GetInt(0, 100) <= chance {
+			return gen.generate(s, rule)
+		}
+	}
+}
+
+// GenerateCustom makes up a name from the set's syllables using rule, rather
+// than one of the set's own rules.
+func (gen *NameGenerator) GenerateCustom(set, rule string) (string, error) {
+	s, ok := gen.sets[set]
+	if !ok {
+		return "", fmt.Errorf("tcod: unknown syllable set %q", set)
+	}
+	if err := s.checkRule(rule); err != nil {
+		return "", err
+	}
+	return gen.generate(s, rule)
+}
+
+// generate expands rule until it makes a word without triple letters or illegal
+// strings.
+func (gen *NameGenerator) generate(s *nameSet, rule string) (string, error) {
+	_, start := ruleChance(rule)
+	for try := 0; try < nameGenMaxTries; try++ {
+		word := pruneSpaces(gen.expand(s, rule[start:]))
+		if word != "" && !hasTriples(word) && !s.hasIllegal(word) {
+			return word, nil
+		}
+	}
+	return "", fmt.Errorf("tcod: syllable set %q can't make a legal name from rule %q", s.name, rule)
+}
+
+// expand replaces the wildcards in a rule checked by checkRule with syllables.
+func (gen *NameGenerator) expand(s *nameSet, rule string) string {
+	var word strings.Builder
+	for i := 0; i < len(rule); i++ {
+		switch c := rule[i]; c {
+		case '/':
+			if i+1 < len(rule) {
+				i++
+				word.WriteByte(rule[i])
+			}
+		case '_':
+			word.WriteByte(' ')
+		case '$':
+			chance := 100
+			if j := digitsEnd(rule, i+1); j > i+1 {
+				chance, _ = strconv.Atoi(rule[i+1 : j])
+				i = j - 1
+			}
+			i++
+			wildcard := rule[i]
+			if chance < gen.random.GetInt(0, 100) {
+				continue
+			}
+			if wildcard == '?' {
+				wildcard = 'v'
+				if gen.random.GetInt(0, 1) == 1 {
+					wildcard = 'c'
+				}
+			}
+			list := s.lists[wildcard]
+			word.WriteString(list[gen.random.GetInt(0, len(list)-1)])
+		default:
+			word.WriteByte(c)
+		}
+	}
+	return word.String()
+}
+
+// ruleChance returns the percent chance from a rule's "%NN" prefix, or 100 if it
+// has none, along with the index the rule proper starts at.
+func ruleChance(rule string) (chance, start int) {
+	if !strings.HasPrefix(rule, "%") {
+		return 100, 0
+	}
+	end := digitsEnd(rule, 1)
+	chance, _ = strconv.Atoi(rule[1:end])
+	return chance, end
+}
+
+// digitsEnd returns the index of the first non-digit in s at or after i.
+func digitsEnd(s string, i int) int {
+	for i < len(s) && s[i] >= '0' && s[i] <= '9' {
+		i++
+	}
+	return i
+}
+
+// checkRule returns an error if rule uses an unknown wildcard, or one whose list
+// is empty in this set.
+func (s *nameSet) checkRule(rule string) error {
+	if strings.HasPrefix(rule, "%") && digitsEnd(rule, 1) == 1 {
+		return fmt.Errorf("tcod: rule %q has no chance after %%", rule)
+	}
+	_, start := ruleChance(rule)
+	for i := start; i < len(rule); i++ {
+		switch rule[i] {
+		case '/':
+			i++
+		case '$':
+			i = digitsEnd(rule, i+1)
+			if i == len(rule) {
+				return fmt.Errorf("tcod: rule %q ends with an incomplete wildcard", rule)
+			}
+			wildcard := rule[i]
+			if wildcard == '?' {
+				if len(s.lists['v']) == 0 || len(s.lists['c']) == 0 {
+					return fmt.Errorf("tcod: rule %q uses $? but syllable set %q lacks vocals or consonants", rule, s.name)
+				}
+				continue
+			}
+			if !isNameWildcard(wildcard) {
+				return fmt.Errorf("tcod: rule %q has unknown wildcard $%c", rule, wildcard)
+			}
+			if len(s.lists[wildcard]) == 0 {
+				return fmt.Errorf("tcod: rule %q uses $%c but syllable set %q has none", rule, wildcard, s.name)
+			}
+		}
+	}
+	return nil
+}
+
+func isNameWildcard(c byte) bool {
+	for _, wildcard := range nameSetProperties {
+		if c == wildcard {
+			return true
+		}
+	}
+	return false
+}
+
+// hasIllegal returns true if the word contains one of the set's illegal strings,
+// ignoring case.
+func (s *nameSet) hasIllegal(word string) bool {
+	word = strings.ToLower(word)
+	for _, illegal := range s.illegal {
+		if strings.Contains(word, illegal) {
+			return true
+		}
+	}
+	return false
+}
+
+// hasTriples returns true if the word has the same letter three times in a row,
+// ignoring case.
+func hasTriples(word string) bool {
+	runes := []rune(strings.ToLower(word))
+	for i := 2; i < len(runes); i++ {
+		if runes[i] == runes[i-1] && runes[i] == runes[i-2] {
+			return true
+		}
+	}
+	return false
+}
+
+// pruneSpaces trims a word and collapses its runs of spaces.
+func pruneSpaces(word string) string {
+	return strings.Join(strings.Fields(word), " ")
+}
+
+//
+// Syllable set files
+//
+
+// parse reads the sets defined in data, a file of the form:
+//
+//	name "Set name" {
+//	  syllablesStart = "Ab, Adal, Adi"
+//	  syllablesEnd = "adr, afr, all"
+//	  rules = "$s$e"
+//	}
+//
+// None of the sets are added unless they all parse.
+func (gen *NameGenerator) parse(source string, data []byte) error {
+	p := nameSetParser{newCfgScanner(source, data)}
+	var sets []*nameSet
+	for {
+		token, err := p.next()
+		if err != nil {
+			return err
+		}
+		if token == "" {
+			break
+		}
+		if token != "name" {
+			return p.errorf("expected \"name\", found %q", token)
+		}
+		s, err := p.set()
+		if err != nil {
+			return err
+		}
+		sets = append(sets, s)
+	}
+
+	for _, s := range sets {
+		gen.sets[s.name] = s
+	}
+	return nil
+}
+
+type nameSetParser struct {
+	*cfgScanner
+}
+
+// set parses a set's name and the properties between its braces.
+func (p nameSetParser) set() (*nameSet, error) {
+	name, err := p.expectString()
+	if err != nil {
+		return nil, err
+	}
+	if err := p.expect("{"); err != nil {
+		return nil, err
+	}
+
+	s := &nameSet{name: name, lists: make(map[byte][]string)}
+	seen := make(map[string]bool)
+	for {
+		property, err := p.next()
+		if err != nil {
+			return nil, err
+		}
+		if property == "}" && !p.quoted {
+			break
+		}
+		if property == "" {
+			return nil, p.errorf("set %q is missing its closing }", name)
+		}
+		if seen[property] {
+			return nil, p.errorf("set %q defines %s twice", name, property)
+		}
+		seen[property] = true
+		if err := p.expect("="); err != nil {
+			return nil, err
+		}
+		value, err := p.expectString()
+		if err != nil {
+			return nil, err
+		}
+
+		switch wildcard, ok := nameSetProperties[property]; {
+		case ok:
+			s.lists[wildcard] = splitNameList(value, false)
+		case property == "rules":
+			s.rules = splitNameList(value, true)
+		case property == "illegal":
+			for _, illegal := range splitNameList(value, false) {
+				s.illegal = append(s.illegal, strings.ToLower(illegal))
+			}
+		default:
+			return nil, p.errorf("set %q has unknown property %q", name, property)
+		}
+	}
+
+	if len(s.rules) == 0 {
+		return nil, p.errorf("set %q has no rules", name)
+	}
+	for _, rule := range s.rules {
+		if err := s.checkRule(rule); err != nil {
+			return nil, p.errorf("set %q: %v", name, strings.TrimPrefix(err.Error(), "tcod: "))
+		}
+	}
+	return s, nil
+}
+
+// splitNameList splits a property's value into its syllables, dropping repeats.
+// Letters, apostrophes and dashes make up syllables, an underscore is a space,
+// and a slash includes the character after it; anything else separates
+// syllables.  Rules also keep wildcards, chances, slashes and underscores for
+// expanding later.
+func splitNameList(value string, rules bool) []string {
+	var items []string
+	seen := make(map[string]bool)
+	var item []byte
+	add := func() {
+		if len(item) > 0 && !seen[string(item)] {
+			seen[string(item)] = true
+			items = append(items, string(item))
+		}
+		item = item[:0]
+	}
+
+	for i := 0; i < len(value); i++ {
+		c := value[i]
+		switch {
+		case c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c == '\'' || c == '-':
+			item = append(item, c)
+		case c == '/':
+			if i+1 == len(value) {
+				item = item[:0] // a trailing slash drops the syllable
+				break
+			}
+			i++
+			if rules {
+				item = append(item, '/')
+			}
+			item = append(item, value[i])
+		case c == '_':
+			if rules {
+				item = append(item, '_')
+			} else {
+				item = append(item, ' ')
+			}
+		case rules && (c == '$' || c == '%' || c == '?' || c >= '0' && c <= '9'):
+			item = append(item, c)
+		default:
+			add()
+		}
+	}
+	add()
+	return items
+}
@@ -0,0 +1,229 @@
+package tcod
+
+import (
+	"io/fs"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+const namegenSamples = "../sample/data/namegen"
+
+func TestNameGeneratorParseFS(t *testing.T) {
+	fsys := os.DirFS(namegenSamples)
+	filenames, err := fs.Glob(fsys, "*.cfg")
+	if err != nil || len(filenames) == 0 {
+		t.Fatalf("no sample syllable sets: %v", err)
+	}
+	for _, filename := range filenames {
+		gen := NewNameGenerator(NewRandomFromSeed(1))
+		if err := gen.ParseFS(fsys, filename); err != nil {
+			t.Errorf("%s: %v", filename, err)
+			continue
+		}
+		if len(gen.Sets()) == 0 {
+			t.Errorf("%s has no sets", filename)
+		}
+		for _, set := range gen.Sets() {
+			for i := 0; i < 20; i++ {
+				name, err := gen.Generate(set)
+				if err != nil {
+					t.Fatalf("%s: %v", filename, err)
+				}
+				if name == "" || hasTriples(name) || strings.TrimSpace(name) != name {
+					t.Errorf("%s: set %q made the name %q", filename, set, name)
+				}
+			}
+		}
+	}
+
+	gen := NewNameGenerator(nil)
+	if err := gen.ParseFS(fsys, "*.cfg"); err != nil {
+		t.Fatal(err)
+	}
+	sets := gen.Sets()
+	if !sort.StringsAreSorted(sets) {
+		t.Errorf("sets aren't sorted: %v", sets)
+	}
+	for _, set := range []string{"demon male", "demon female"} {
+		if !gen.HasSet(set) {
+			t.Errorf("no set %q in %v", set, sets)
+		}
+	}
+	if gen.HasSet("demon") {
+		t.Error("HasSet found a set that isn't defined")
+	}
+	if err := gen.ParseFS(fsys, "missing*.cfg"); err == nil || !strings.Contains(err.Error(), "matches no files") {
+		t.Errorf("ParseFS of a pattern matching no files gave the error %v", err)
+	}
+}
+
+func TestNameGeneratorSeeded(t *testing.T) {
+	names := func(seed uint32) []string {
+		gen := NewNameGenerator(NewRandomFromSeed(seed))
+		if err := gen.ParseFS(os.DirFS(namegenSamples), "*.cfg"); err != nil {
+			t.Fatal(err)
+		}
+		var result []string
+		for _, set := range gen.Sets() {
+			name, err := gen.Generate(set)
+			if err != nil {
+				t.Fatal(err)
+			}
+			custom, err := gen.GenerateCustom(set, "$s$e")
+			if err != nil {
+				t.Fatal(err)
+			}
+			result = append(result, name, custom)
+		}
+		return result
+	}
+
+	first := names(5)
+	if again := names(5); !reflect.DeepEqual(again, first) {
+		t.Errorf("the same seed made the names\n%v\nand\n%v", first, again)
+	}
+	if other := names(6); reflect.DeepEqual(other, first) {
+		t.Error("different seeds made the same names")
+	}
+}
+
+func TestNameGeneratorRules(t *testing.T) {
+	gen := NewNameGenerator(NewRandomFromSeed(1))
+	err := gen.ParseString(`
+		name "test" {
+			syllablesStart = "Ab"
+			syllablesEnd = "ba"
+			rules = "$s$e"
+		}
+		name "spaced" {
+			syllablesStart = "Al_Ab"
+			syllablesEnd = "ba, ba"
+			rules = "_$s__$e_"
+		}
+		name "illegal" {
+			syllablesStart = "Ab, Ka"
+			syllablesEnd = "ba"
+			illegal = "KAB"
+			rules = "$s$e"
+		}
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		set, rule, want string
+	}{
+		{"test", "", "Abba"},
+		{"test", "$e_$s", "ba Ab"},
+		{"test", "$s/$/_$e", "Ab$_ba"},
+		{"test", "$100s$e", "Abba"},
+		// GenerateCustom ignores a rule's chance.
+		{"test", "%10$e$s", "baAb"},
+		{"spaced", "", "Al Ab ba"},
+		{"illegal", "", "Abba"},
+	}
+	for _, test := range tests {
+		for i := 0; i < 20; i++ {
+			var name string
+			var err error
+			if test.rule == "" {
+				name, err = gen.Generate(test.set)
+			} else {
+				name, err = gen.GenerateCustom(test.set, test.rule)
+			}
+			if err != nil {
+				t.Fatalf("%s %q: %v", test.set, test.rule, err)
+			}
+			if name != test.want {
+				t.Errorf("%s %q made %q, want %q", test.set, test.rule, name, test.want)
+				break
+			}
+		}
+	}
+
+	// A rule with a chance is only sometimes used.
+	if err := gen.ParseString(`name "test" { syllablesStart = "Ab" syllablesEnd = "ba" rules = "%50$s$e, $e" }`); err != nil {
+		t.Fatal(err)
+	}
+	counts := make(map[string]int)
+	for i := 0; i < 200; i++ {
+		name, err := gen.Generate("test")
+		if err != nil {
+			t.Fatal(err)
+		}
+		counts[name]++
+	}
+	if len(counts) != 2 || counts["Abba"] == 0 || counts["ba"] <= counts["Abba"] {
+		t.Errorf("made the names %v, want fewer Abba than ba", counts)
+	}
+}
+
+func TestNameGeneratorErrors(t *testing.T) {
+	set := func(properties string) string {
+		return `name "bad" {
+			syllablesStart = "Ab, Ka"
+			syllablesEnd = "ba, ka"
+			` + properties + `
+		}`
+	}
+	tests := []struct {
+		name, data, err string
+	}{
+		{"no chance", set(`rules = "%$s$e"`), "no chance after %"},
+		{"unknown wildcard", set(`rules = "$s$x"`), "unknown wildcard $x"},
+		{"incomplete wildcard", set(`rules = "$s$"`), "incomplete wildcard"},
+		{"empty list", set(`syllablesMiddle = ""
+			rules = "$s$m$e"`), "uses $m but"},
+		{"no vocals", set(`rules = "$s$?"`), "lacks vocals or consonants"},
+		{"no rules", set(``), "has no rules"},
+		{"empty rules", set(`rules = ""`), "has no rules"},
+		{"duplicate property", set(`rules = "$s"
+			syllablesEnd = "ra"`), "defines syllablesEnd twice"},
+		{"unknown property", set(`syllablesOther = "ra"
+			rules = "$s"`), "unknown property \"syllablesOther\""},
+		{"unterminated string", set(`rules = "$s$e
+			"`), "unterminated string"},
+		{"missing brace", `name "bad" { rules = "$s" `, "missing its closing }"},
+		{"not a set", `rules = "$s"`, "expected \"name\""},
+	}
+	for _, test := range tests {
+		gen := NewNameGenerator(NewRandomFromSeed(1))
+		err := gen.ParseString(`name "good" { syllablesStart = "Ab" rules = "$s" }` + "\n" + test.data)
+		if err == nil {
+			t.Errorf("%s: parsed", test.name)
+			continue
+		}
+		if !strings.Contains(err.Error(), test.err) || !strings.HasPrefix(err.Error(), "tcod: string:") {
+			t.Errorf("%s: error is %q, want one containing %q", test.name, err, test.err)
+		}
+		// None of the sets are added if one is bad.
+		if sets := gen.Sets(); len(sets) != 0 {
+			t.Errorf("%s: added the sets %v", test.name, sets)
+		}
+	}
+
+	gen := NewNameGenerator(NewRandomFromSeed(1))
+	if err := gen.ParseString(set(`illegal = "a"
+		rules = "$s$e"`)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := gen.Generate("bad"); err == nil || !strings.Contains(err.Error(), "legal name") {
+		t.Errorf("Generate with only illegal names gave the error %v", err)
+	}
+	if _, err := gen.GenerateCustom("bad", "$e"); err == nil || !strings.Contains(err.Error(), "legal name") {
+		t.Errorf("GenerateCustom with only illegal names gave the error %v", err)
+	}
+	if _, err := gen.GenerateCustom("bad", "$s$q"); err == nil || !strings.Contains(err.Error(), "$q") {
+		t.Errorf("GenerateCustom with an unknown wildcard gave the error %v", err)
+	}
+	if _, err := gen.Generate("missing"); err == nil || !strings.Contains(err.Error(), "unknown syllable set") {
+		t.Errorf("Generate of an unknown set gave the error %v", err)
+	}
+	if _, err := gen.GenerateCustom("missing", "$s"); err == nil || !strings.Contains(err.Error(), "unknown syllable set") {
+		t.Errorf("GenerateCustom of an unknown set gave the error %v", err)
+	}
+}
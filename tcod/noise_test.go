@@ -0,0 +1,50 @@
+package tcod
+
+import (
+	"math"
+	"testing"
+)
+
+func TestNoiseSample(t *testing.T) {
+	noise := NewNoise(2, NewRandomFromSeed(3))
+	xs := []float32{0.1, 1.7, -3.2, 5}
+	ys := []float32{2.5, -0.3, 4.4}
+
+	// Only as many points as the shortest slice are sampled.
+	out := []float32{42, 42, 42, 42}
+	if n := noise.Sample(NOISE_SIMPLEX, out, xs, ys); n != 3 {
+		t.Fatalf("sampled %d points, want 3", n)
+	}
+	for i := 0; i < 3; i++ {
+		want := noise.GetEx(FloatArray{xs[i], ys[i]}, NOISE_SIMPLEX)
+		if math.Abs(float64(out[i]-want)) > 1e-6 {
+			t.Errorf("sample %d is %v, want %v", i, out[i], want)
+		}
+	}
+	if out[3] != 42 {
+		t.Errorf("the unsampled point was set to %v", out[3])
+	}
+
+	fbm := make([]float32, 3)
+	if n := noise.SampleFbm(NOISE_PERLIN, 4, fbm, xs, ys); n != 3 {
+		t.Fatalf("sampled %d fbm points, want 3", n)
+	}
+	for i := range fbm {
+		want := noise.GetFbmEx(FloatArray{xs[i], ys[i]}, 4, NOISE_PERLIN)
+		if math.Abs(float64(fbm[i]-want)) > 1e-6 {
+			t.Errorf("fbm sample %d is %v, want %v", i, fbm[i], want)
+		}
+	}
+
+	// libtcod would read a missing dimension through a nil pointer.
+	out = []float32{42, 42}
+	if n := noise.Sample(NOISE_SIMPLEX, out, xs); n != 0 || out[0] != 42 {
+		t.Errorf("sampled %d points of a 2D noise with only xs", n)
+	}
+	if n := noise.SampleFbm(NOISE_SIMPLEX, 4, out, xs); n != 0 || out[0] != 42 {
+		t.Errorf("sampled %d fbm points of a 2D noise with only xs", n)
+	}
+	if n := noise.SampleTurbulence(NOISE_SIMPLEX, 4, out); n != 0 || out[0] != 42 {
+		t.Errorf("sampled %d turbulence points of a 2D noise without coordinates", n)
+	}
+}
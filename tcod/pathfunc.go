@@ -0,0 +1,68 @@
+package tcod
+
+/*
+ #include <stdint.h>
+*/
+import "C"
+
+import (
+	"sync"
+)
+
+//
+// Path cost callbacks
+//
+
+// PathFunc returns the cost of moving from one cell to an adjacent cell.  A cost
+// of 0 or less means the move is blocked.  It's called while a path is being
+// computed, and shouldn't change the path finder it belongs to.
+type PathFunc func(xFrom, yFrom, xTo, yTo int) float32
+
+// pathFuncHandle identifies a registered PathFunc.  Go functions can't be handed
+// to C, so libtcod is given the handle instead, and goPathCost looks the function
+// up again.  Zero is not a valid handle.
+type pathFuncHandle uintptr
+
+var pathFuncs struct {
+	sync.Mutex
+	funcs []PathFunc
+	free  []pathFuncHandle
+}
+
+func registerPathFunc(f PathFunc) pathFuncHandle {
+	pathFuncs.Lock()
+	defer pathFuncs.Unlock()
+
+	if n := len(pathFuncs.free); n > 0 {
+		handle := pathFuncs.free[n-1]
+		pathFuncs.free = pathFuncs.free[:n-1]
+		pathFuncs.funcs[handle-1] = f
+		return handle
+	}
+	pathFuncs.funcs = append(pathFuncs.funcs, f)
+	return pathFuncHandle(len(pathFuncs.funcs))
+}
+
+func (handle pathFuncHandle) get() PathFunc {
+	pathFuncs.Lock()
+	defer pathFuncs.Unlock()
+	return pathFuncs.funcs[handle-1]
+}
+
+// release frees the handle for reuse.  It does nothing for the zero handle, which
+// path finders built from a Map have.
+func (handle pathFuncHandle) release() {
+	if handle == 0 {
+		return
+	}
+	pathFuncs.Lock()
+	defer pathFuncs.Unlock()
+	pathFuncs.funcs[handle-1] = nil
+	pathFuncs.free = append(pathFuncs.free, handle)
+}
+
+//export goPathCost
+func goPathCost(xFrom, yFrom, xTo, yTo C.int, handle C.uintptr_t) C.float {
+	f := pathFuncHandle(handle).get()
+	return C.float(f(int(xFrom), int(yFrom), int(xTo), int(yTo)))
+}
@@ -0,0 +1,227 @@
+package tcod
+
+/*
+ #include "include/libtcod.h"
+ #include "include/libtcod/libtcod_int.h"
+
+ // _random_uint64 draws 64 bits 16 at a time, since TCOD_random_get_int can't
+ // return a full 32 bit range.  The distribution must be linear.
+ static uint64_t _random_uint64(TCOD_random_t r) {
+ 	uint64_t result = 0;
+ 	for (int i = 0; i < 4; i++) {
+ 		result = (result << 16) | (uint64_t)TCOD_random_get_int(r, 0, 0xFFFF);
+ 	}
+ 	return result;
+ }
+*/
+import "C"
+
+import (
+	"encoding/binary"
+	"errors"
+	"math/rand"
+	"runtime"
+	"unsafe"
+)
+
+//
+// math/rand support
+//
+
+// Random is a rand.Source64, and also a math/rand/v2 Source, so a single seeded
+// Random can drive the standard library and other Go packages:
+//
+//	rnd := rand.New(tcod.NewRandomFromSeed(seed))
+var _ rand.Source64 = (*Random)(nil)
+
+// Uint64 returns 64 uniformly random bits from the generator, whatever its
+// distribution is set to.
+func (random *Random) Uint64() uint64 {
+	if random.distribution != DistributionLinear {
+		C.TCOD_random_set_distribution(random.Data, DistributionLinear)
+		defer C.TCOD_random_set_distribution(random.Data, C.TCOD_distribution_t(random.distribution))
+	}
+	return uint64(C._random_uint64(random.Data))
+}
+
+// Int63 returns a non-negative random 63 bit integer.
+func (random *Random) Int63() int64 {
+	return int64(random.Uint64() >> 1)
+}
+
+// Seed restarts the generator from seed, keeping its algorithm and distribution.
+// libtcod seeds are 32 bits, so only the low 32 bits of seed are used.
+func (random *Random) Seed(seed int64) {
+	fresh := C.TCOD_random_new_from_seed(C.TCOD_random_algo_t(random.algo), C.uint32_t(seed))
+	defer C.TCOD_random_delete(fresh)
+	C.TCOD_random_restore(random.Data, fresh)
+	C.TCOD_random_set_distribution(random.Data, C.TCOD_distribution_t(random.distribution))
+}
+
+// Shuffle randomizes the order of n elements, with swap exchanging the elements
+// at i and j.
+func (random *Random) Shuffle(n int, swap func(i, j int)) {
+	rand.New(random).Shuffle(n, swap)
+}
+
+// Perm returns a random permutation of the integers from 0 to n-1.
+func (random *Random) Perm(n int) []int {
+	return rand.New(random).Perm(n)
+}
+
+// Choice returns a random index from 0 to n-1, for picking an element of a slice
+// of length n.  It returns -1 if n is 0 or less.
+func (random *Random) Choice(n int) int {
+	if n <= 0 {
+		return -1
+	}
+	return int(rand.New(random).Int63n(int64(n)))
+}
+
+// WeightedChoice returns a random index into weights, with each index chosen in
+// proportion to its weight.  Weights of 0 or less are never chosen, and it
+// returns -1 if no weight is above 0.
+func (random *Random) WeightedChoice(weights []float64) int {
+	total := 0.0
+	for _, w := range weights {
+		if w > 0 {
+			total += w
+		}
+	}
+	if total <= 0 {
+		return -1
+	}
+
+	r := rand.New(random).Float64() * total
+	last := -1
+	for i, w := range weights {
+		if w <= 0 {
+			continue
+		}
+		if r < w {
+			return i
+		}
+		r -= w
+		last = i
+	}
+	return last // rounding left r just past the end
+}
+
+//
+// Random state serialization
+//
+
+// randomStateVersion starts the data written by Random.MarshalBinary.  It's
+// followed by the algorithm and distribution bytes, then the generator state as
+// little-endian integers: the position and 624 words of the Mersenne twister, or
+// the position, carry and 4096 words of CMWC.
+const randomStateVersion = 1
+
+// MarshalBinary captures the generator's full state, so a save game can carry on
+// the same sequence of numbers after it's loaded.  It fails for a nil or zero
+// Random, which has no generator.
+func (random *Random) MarshalBinary() ([]byte, error) {
+	if random == nil || random.Data == nil {
+		return nil, errors.New("tcod: no random generator")
+	}
+	state := random.Data
+	var words []C.uint32_t
+	var header []uint32
+	if state.algo == RNG_CMWC {
+		words = state.Q[:]
+		header = []uint32{uint32(state.cur), uint32(state.c)}
+	} else {
+		words = state.mt[:]
+		header = []uint32{uint32(state.cur_mt)}
+	}
+
+	data := []byte{randomStateVersion, byte(state.algo), byte(state.distribution)}
+	for _, v := range header {
+		data = appendUint32(data, v)
+	}
+	for _, v := range words {
+		data = appendUint32(data, uint32(v))
+	}
+	return data, nil
+}
+
+func appendUint32(data []byte, v uint32) []byte {
+	var buf [4]byte
+	binary.LittleEndian.PutUint32(buf[:], v)
+	return append(data, buf[:]...)
+}
+
+// UnmarshalBinary restores a state captured by MarshalBinary, replacing the
+// generator's algorithm and distribution too.  A zero Random is given a new
+// generator.
+func (random *Random) UnmarshalBinary(data []byte) error {
+	if len(data) < 3 || data[0] != randomStateVersion {
+		return errors.New("tcod: unknown random generator state")
+	}
+	algo, distribution := RandomAlgo(data[1]), Distribution(data[2])
+	if (algo != RNG_MT && algo != RNG_CMWC) || distribution > DistributionGaussianRangeInverse {
+		return errors.New("tcod: invalid random generator state")
+	}
+
+	nheader, nwords := 1, 624
+	if algo == RNG_CMWC {
+		nheader, nwords = 2, 4096
+	}
+	data = data[3:]
+	if len(data) != (nheader+nwords)*4 {
+		return errors.New("tcod: invalid random generator state")
+	}
+	word := func(i int) uint32 {
+		return binary.LittleEndian.Uint32(data[i*4:])
+	}
+
+	if random.Data == nil {
+		random.Data = C.TCOD_random_new(C.TCOD_random_algo_t(algo))
+		runtime.SetFinalizer(random, deleteRandom)
+	}
+	state := random.Data
+	state.algo = C.TCOD_random_algo_t(algo)
+	state.distribution = C.TCOD_distribution_t(distribution)
+	if algo == RNG_CMWC {
+		state.cur, state.c = C.int(int32(word(0))), C.uint32_t(word(1))
+		for i := range state.Q {
+			state.Q[i] = C.uint32_t(word(nheader + i))
+		}
+	} else {
+		state.cur_mt = C.int(int32(word(0)))
+		for i := range state.mt {
+			state.mt[i] = C.uint32_t(word(nheader + i))
+		}
+	}
+	random.algo, random.distribution = algo, distribution
+	return nil
+}
+
+// PutRandom writes the generator's state to the zip.  A nil or zero Random is
+// written as an empty state, which GetRandom reads back as nil.
+func (zip *Zip) PutRandom(random *Random) {
+	data, err := random.MarshalBinary()
+	if err != nil {
+		zip.PutInt(0)
+		return
+	}
+	zip.PutInt(len(data))
+	zip.PutData(len(data), unsafe.Pointer(&data[0]))
+}
+
+// GetRandom reads a generator written by PutRandom.  It returns nil if the zip
+// doesn't hold a valid generator state.
+func (zip *Zip) GetRandom() *Random {
+	n := zip.GetInt()
+	if n <= 0 || uint32(n) > zip.GetRemainingBytes() {
+		return nil
+	}
+	data := make([]byte, n)
+	zip.GetData(n, unsafe.Pointer(&data[0]))
+
+	random := new(Random)
+	if err := random.UnmarshalBinary(data); err != nil {
+		return nil
+	}
+	return random
+}
@@ -0,0 +1,274 @@
+package tcod
+
+import (
+	"math"
+	"math/rand"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+// draws returns the next n numbers from random.
+func draws(random *Random, n int) []float32 {
+	result := make([]float32, n)
+	for i := range result {
+		result[i] = random.GetFloat(0, 1000)
+	}
+	return result
+}
+
+func sameDraws(t *testing.T, name string, got, want []float32) {
+	t.Helper()
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("%s: draw %d is %v, want %v", name, i, got[i], want[i])
+			return
+		}
+	}
+}
+
+// newUsedRandom returns a generator part way through its sequence, so its state
+// isn't the one it was seeded with.
+func newUsedRandom(algo RandomAlgo, distribution Distribution) *Random {
+	random := NewRandomFromSeedWithAlgo(42, algo)
+	random.SetDistribution(distribution)
+	draws(random, 1000)
+	return random
+}
+
+func TestRandomMarshalBinary(t *testing.T) {
+	tests := []struct {
+		name         string
+		algo         RandomAlgo
+		distribution Distribution
+	}{
+		{"MT", RNG_MT, DistributionLinear},
+		{"CMWC", RNG_CMWC, DistributionLinear},
+		{"MT gaussian", RNG_MT, DistributionGaussianRange},
+		{"CMWC gaussian", RNG_CMWC, DistributionGaussianRangeInverse},
+	}
+	for _, test := range tests {
+		random := newUsedRandom(test.algo, test.distribution)
+		data, err := random.MarshalBinary()
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		// More than the 624 words of the Mersenne twister, so it has to
+		// regenerate its state from the restored words.
+		want := draws(random, 5000)
+
+		restored := new(Random)
+		if err := restored.UnmarshalBinary(data); err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		sameDraws(t, test.name, draws(restored, 5000), want)
+
+		// Restoring over a generator of the other algorithm replaces it.
+		other := NewRandomFromSeedWithAlgo(7, RNG_MT+RNG_CMWC-test.algo)
+		if err := other.UnmarshalBinary(data); err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		sameDraws(t, test.name+" over another generator", draws(other, 5000), want)
+	}
+}
+
+func TestRandomUnmarshalBinaryErrors(t *testing.T) {
+	data, _ := newUsedRandom(RNG_CMWC, DistributionLinear).MarshalBinary()
+	for name, bad := range map[string][]byte{
+		"empty":        nil,
+		"version":      append([]byte{99}, data[1:]...),
+		"algorithm":    append([]byte{data[0], 9}, data[2:]...),
+		"distribution": append([]byte{data[0], data[1], 99}, data[3:]...),
+		"truncated":    data[:len(data)-1],
+		"too long":     append(append([]byte(nil), data...), 0),
+	} {
+		if err := new(Random).UnmarshalBinary(bad); err == nil {
+			t.Errorf("UnmarshalBinary accepted %s data", name)
+		}
+	}
+}
+
+func TestRandomMarshalBinaryNil(t *testing.T) {
+	for name, random := range map[string]*Random{"nil": nil, "zero": new(Random)} {
+		if data, err := random.MarshalBinary(); err == nil {
+			t.Errorf("marshalled a %s Random as %d bytes", name, len(data))
+		}
+	}
+}
+
+func TestZipRandom(t *testing.T) {
+	mt := newUsedRandom(RNG_MT, DistributionLinear)
+	cmwc := newUsedRandom(RNG_CMWC, DistributionGaussian)
+
+	zip := NewZip()
+	zip.PutInt(7)
+	zip.PutRandom(mt)
+	zip.PutRandom(cmwc)
+	zip.PutRandom(new(Random))
+	zip.PutInt(8)
+	filename := filepath.Join(t.TempDir(), "random.sav")
+	zip.SaveToFile(filename)
+	wantMT, wantCMWC := draws(mt, 2000), draws(cmwc, 2000)
+
+	loaded := NewZip()
+	loaded.LoadFromFile(filename)
+	if v := loaded.GetInt(); v != 7 {
+		t.Fatalf("read %d before the generators, want 7", v)
+	}
+	gotMT, gotCMWC := loaded.GetRandom(), loaded.GetRandom()
+	if gotMT == nil || gotCMWC == nil {
+		t.Fatal("GetRandom returned nil")
+	}
+	if zero := loaded.GetRandom(); zero != nil {
+		t.Error("read a generator where a zero Random was written")
+	}
+	if v := loaded.GetInt(); v != 8 {
+		t.Errorf("read %d after the generators, want 8", v)
+	}
+	sameDraws(t, "MT", draws(gotMT, 2000), wantMT)
+	sameDraws(t, "CMWC", draws(gotCMWC, 2000), wantCMWC)
+}
+
+func TestRandomSource64(t *testing.T) {
+	a, b := NewRandomFromSeed(11), NewRandomFromSeed(11)
+	b.SetDistribution(DistributionGaussianRange)
+	for i := 0; i < 1000; i++ {
+		// The distribution doesn't change the bits.
+		if x, y := a.Uint64(), b.Uint64(); x != y {
+			t.Fatalf("draw %d is %#x with a linear distribution and %#x with a gaussian one", i, x, y)
+		}
+		if v := a.Int63(); v < 0 {
+			t.Fatalf("Int63 returned %d", v)
+		}
+		b.Int63()
+	}
+
+	// Uint64 puts the distribution back when it's done.
+	c := NewRandomFromSeed(11)
+	c.SetDistribution(DistributionGaussianRange)
+	for i := 0; i < 2000; i++ {
+		c.Uint64()
+	}
+	sameDraws(t, "gaussian after Uint64", draws(b, 100), draws(c, 100))
+
+	// Seed starts the sequence again.
+	a.Seed(11)
+	want := NewRandomFromSeed(11)
+	for i := 0; i < 10; i++ {
+		if x, y := a.Uint64(), want.Uint64(); x != y {
+			t.Fatalf("draw %d after Seed is %#x, want %#x", i, x, y)
+		}
+	}
+
+	// A seeded Random drives math/rand the same way every time.
+	x, y := rand.New(NewRandomFromSeed(5)), rand.New(NewRandomFromSeed(5))
+	for i := 0; i < 100; i++ {
+		if p, q := x.Intn(1000), y.Intn(1000); p != q {
+			t.Fatalf("math/rand draw %d is %d and %d from the same seed", i, p, q)
+		}
+	}
+}
+
+// checkPermutation fails the test unless perm holds each of 0 to n-1 once.
+func checkPermutation(t *testing.T, name string, perm []int, n int) {
+	t.Helper()
+	sorted := append([]int(nil), perm...)
+	sort.Ints(sorted)
+	if len(sorted) != n {
+		t.Fatalf("%s: %d elements, want %d", name, len(sorted), n)
+	}
+	for i, v := range sorted {
+		if v != i {
+			t.Fatalf("%s: %v isn't a permutation of 0 to %d", name, perm, n-1)
+		}
+	}
+}
+
+func TestRandomPerm(t *testing.T) {
+	for _, n := range []int{0, 1, 2, 10, 100} {
+		perm := NewRandomFromSeed(3).Perm(n)
+		checkPermutation(t, "Perm", perm, n)
+		if again := NewRandomFromSeed(3).Perm(n); !reflect.DeepEqual(again, perm) {
+			t.Errorf("Perm(%d) gave %v and %v from the same seed", n, perm, again)
+		}
+	}
+	if perm := NewRandomFromSeed(3).Perm(100); sort.IntsAreSorted(perm) {
+		t.Error("Perm(100) left the integers in order")
+	}
+}
+
+func TestRandomShuffle(t *testing.T) {
+	shuffled := func(seed uint32, n int) []int {
+		values := make([]int, n)
+		for i := range values {
+			values[i] = i
+		}
+		NewRandomFromSeed(seed).Shuffle(n, func(i, j int) {
+			values[i], values[j] = values[j], values[i]
+		})
+		return values
+	}
+
+	for _, n := range []int{0, 1, 2, 50} {
+		values := shuffled(9, n)
+		checkPermutation(t, "Shuffle", values, n)
+		if again := shuffled(9, n); !reflect.DeepEqual(again, values) {
+			t.Errorf("shuffling %d gave %v and %v from the same seed", n, values, again)
+		}
+	}
+	if values := shuffled(9, 50); sort.IntsAreSorted(values) {
+		t.Error("shuffling 50 left them in order")
+	}
+}
+
+func TestRandomChoice(t *testing.T) {
+	random := NewRandomFromSeed(4)
+	for _, n := range []int{0, -1} {
+		if i := random.Choice(n); i != -1 {
+			t.Errorf("Choice(%d) is %d, want -1", n, i)
+		}
+	}
+
+	var counts [5]int
+	for i := 0; i < 1000; i++ {
+		c := random.Choice(len(counts))
+		if c < 0 || c >= len(counts) {
+			t.Fatalf("Choice(%d) is %d", len(counts), c)
+		}
+		counts[c]++
+	}
+	for i, n := range counts {
+		if n == 0 {
+			t.Errorf("Choice(%d) never chose %d in 1000 draws", len(counts), i)
+		}
+	}
+}
+
+func TestRandomWeightedChoice(t *testing.T) {
+	random := NewRandomFromSeed(6)
+	for _, weights := range [][]float64{nil, {}, {0}, {0, -1, 0}} {
+		if i := random.WeightedChoice(weights); i != -1 {
+			t.Errorf("WeightedChoice(%v) is %d, want -1", weights, i)
+		}
+	}
+	for i := 0; i < 100; i++ {
+		if c := random.WeightedChoice([]float64{0, -3, 2, 0}); c != 2 {
+			t.Fatalf("WeightedChoice with one weight above 0 chose %d", c)
+		}
+	}
+
+	// Each index is chosen in proportion to its weight.
+	weights := []float64{1, 0, 3, -2, 6}
+	want := []float64{0.1, 0, 0.3, 0, 0.6}
+	const samples = 20000
+	counts := make([]int, len(weights))
+	for i := 0; i < samples; i++ {
+		counts[random.WeightedChoice(weights)]++
+	}
+	for i, n := range counts {
+		if got := float64(n) / samples; math.Abs(got-want[i]) > 0.02 {
+			t.Errorf("index %d was chosen %.3f of the time, want %.1f", i, got, want[i])
+		}
+	}
+}
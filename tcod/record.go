@@ -0,0 +1,440 @@
+package tcod
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"io"
+)
+
+//
+// Input recording and replay
+//
+
+// InputSource supplies a game's input, so live input can be swapped for a
+// recording.  A game reads its events through an InputSource and calls NextFrame
+// once per frame.
+type InputSource interface {
+	// PollEvent returns the next event for this frame, or nil if there are none.
+	PollEvent() InputEvent
+
+	// CheckForEvent works like SysCheckForEvent.
+	CheckForEvent(eventMask int, key *Key, mouse *Mouse) Event
+
+	// NextFrame moves on to the next frame.
+	NextFrame()
+}
+
+// LiveInput reads input from the window.  Context converts mouse coordinates to
+// tiles, as in PollEvent; leave it nil when using the root console.
+type LiveInput struct {
+	Context *Context
+}
+
+func (in LiveInput) PollEvent() InputEvent {
+	return PollEvent(in.Context)
+}
+
+func (in LiveInput) CheckForEvent(eventMask int, key *Key, mouse *Mouse) Event {
+	return SysCheckForEvent(eventMask, key, mouse)
+}
+
+func (in LiveInput) NextFrame() {}
+
+// recordingMagic starts every recording, and identifies its format version.
+const recordingMagic = "TCODREC1"
+
+// Entry tags in a recording.
+const (
+	recKeyDown byte = iota + 1
+	recKeyUp
+	recTextInput
+	recMouseMotion
+	recMouseButton
+	recMouseWheel
+	recWindowResized
+	recWindowFocus
+	recQuit
+	recCheck
+)
+
+// Recorder passes input through from another InputSource while writing it to a
+// recording, tagged with the frame it arrived in.  Every call to CheckForEvent is
+// recorded, including those that return EventNone, since they still report the
+// mouse position.
+type Recorder struct {
+	source InputSource
+	w      *bufio.Writer
+	frame  uint64
+	last   uint64 // frame of the last entry written
+	buf    [binary.MaxVarintLen64]byte
+}
+
+// NewRecorder starts a recording of source on w.  Seed is saved with the
+// recording, so a replay can seed its Random generators the same way.
+func NewRecorder(w io.Writer, source InputSource, seed uint32) (*Recorder, error) {
+	r := &Recorder{source: source, w: bufio.NewWriter(w)}
+	r.w.WriteString(recordingMagic)
+	r.putUvarint(uint64(seed))
+	if err := r.w.Flush(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *Recorder) PollEvent() InputEvent {
+	ev := r.source.PollEvent()
+	if ev != nil {
+		r.record(ev)
+	}
+	return ev
+}
+
+func (r *Recorder) CheckForEvent(eventMask int, key *Key, mouse *Mouse) Event {
+	var k Key
+	var m Mouse
+	event := r.source.CheckForEvent(eventMask, &k, &m)
+
+	r.putEntry(recCheck)
+	r.putUvarint(uint64(event))
+	r.putKey(k, false)
+	r.putMouse(m)
+
+	if key != nil {
+		*key = k
+	}
+	if mouse != nil {
+		*mouse = m
+	}
+	return event
+}
+
+func (r *Recorder) NextFrame() {
+	r.source.NextFrame()
+	r.frame++
+}
+
+// Close flushes the recording.  It doesn't close the underlying writer.
+func (r *Recorder) Close() error {
+	return r.w.Flush()
+}
+
+func (r *Recorder) record(ev InputEvent) {
+	switch ev := ev.(type) {
+	case KeyDown:
+		r.putEntry(recKeyDown)
+		r.putKey(ev.Key, ev.Repeat)
+	case KeyUp:
+		r.putEntry(recKeyUp)
+		r.putKey(ev.Key, false)
+	case TextInput:
+		r.putEntry(recTextInput)
+		r.putUvarint(uint64(len(ev.Text)))
+		r.w.WriteString(ev.Text)
+	case MouseMotion:
+		r.putEntry(recMouseMotion)
+		r.putVarints(ev.X, ev.Y, ev.Dx, ev.Dy, ev.Cx, ev.Cy)
+	case MouseButton:
+		r.putEntry(recMouseButton)
+		r.putVarints(int(ev.Button), ev.Clicks, ev.X, ev.Y, ev.Cx, ev.Cy)
+		r.w.WriteByte(flags(ev.Pressed))
+	case MouseWheel:
+		r.putEntry(recMouseWheel)
+		r.putVarints(ev.X, ev.Y)
+	case WindowResized:
+		r.putEntry(recWindowResized)
+		r.putVarints(ev.Width, ev.Height)
+	case WindowFocus:
+		r.putEntry(recWindowFocus)
+		r.w.WriteByte(flags(ev.Focused))
+	case Quit:
+		r.putEntry(recQuit)
+	}
+}
+
+func (r *Recorder) putEntry(tag byte) {
+	r.putUvarint(r.frame - r.last)
+	r.w.WriteByte(tag)
+	r.last = r.frame
+}
+
+func (r *Recorder) putKey(key Key, repeat bool) {
+	r.putVarints(int(key.VK))
+	r.w.WriteByte(key.C)
+	r.w.WriteByte(flags(key.Pressed, key.LAlt, key.LCtrl, key.RAlt, key.RCtrl, key.Shift, repeat))
+}
+
+func (r *Recorder) putMouse(m Mouse) {
+	r.putVarints(m.X, m.Y, m.Dx, m.Dy, m.Cx, m.Cy, m.Dcx, m.Dcy)
+	r.w.WriteByte(flags(m.LButton, m.RButton, m.MButton,
+		m.LButtonPressed, m.RButtonPressed, m.MButtonPressed, m.WheelUp, m.WheelDown))
+}
+
+func (r *Recorder) putUvarint(v uint64) {
+	r.w.Write(r.buf[:binary.PutUvarint(r.buf[:], v)])
+}
+
+func (r *Recorder) putVarints(vs ...int) {
+	for _, v := range vs {
+		r.w.Write(r.buf[:binary.PutVarint(r.buf[:], int64(v))])
+	}
+}
+
+// flags packs up to eight bools into a byte, the first in the lowest bit.
+func flags(bs ...bool) (result byte) {
+	for i, b := range bs {
+		if b {
+			result |= 1 << uint(i)
+		}
+	}
+	return
+}
+
+// flag reports whether bit i of f is set.
+func flag(f byte, i uint) bool {
+	return f&(1<<i) != 0
+}
+
+// recordedEntry is a single event read back from a recording.
+type recordedEntry struct {
+	frame uint64
+	event InputEvent // nil for a CheckForEvent entry
+	check Event
+	key   Key
+	mouse Mouse
+}
+
+// Replay is an InputSource that plays back a recording made by Recorder.  Each
+// event is returned in the same frame it was recorded in, so a game that seeds
+// its Random generators with Seed and calls NextFrame at the same points in its
+// loop sees exactly the same input, and draws exactly the same consoles, as the
+// recorded session.
+type Replay struct {
+	r     *bufio.Reader
+	seed  uint32
+	frame uint64
+	next  *recordedEntry
+	mouse Mouse
+	err   error
+}
+
+// NewReplay reads a recording from r.
+func NewReplay(r io.Reader) (*Replay, error) {
+	p := &Replay{r: bufio.NewReader(r)}
+
+	magic := make([]byte, len(recordingMagic))
+	if _, err := io.ReadFull(p.r, magic); err != nil {
+		return nil, err
+	}
+	if string(magic) != recordingMagic {
+		return nil, errors.New("tcod: not an input recording")
+	}
+	seed, err := binary.ReadUvarint(p.r)
+	if err != nil {
+		return nil, err
+	}
+	p.seed = uint32(seed)
+
+	p.advance()
+	if p.err != nil {
+		return nil, p.err
+	}
+	return p, nil
+}
+
+// Seed returns the random seed saved with the recording.
+func (p *Replay) Seed() uint32 {
+	return p.seed
+}
+
+// PollEvent returns the next event recorded in the current frame, or nil once
+// they've all been returned.
+func (p *Replay) PollEvent() InputEvent {
+	if p.next == nil || p.next.frame != p.frame || p.next.event == nil {
+		return nil
+	}
+	ev := p.next.event
+	p.advance()
+	return ev
+}
+
+// CheckForEvent returns the next CheckForEvent result recorded in the current
+// frame.  Once they've all been returned, it returns EventNone with the last
+// recorded mouse state.
+func (p *Replay) CheckForEvent(eventMask int, key *Key, mouse *Mouse) Event {
+	var event Event
+	var k Key
+	if p.next != nil && p.next.frame == p.frame && p.next.event == nil {
+		event, k, p.mouse = p.next.check, p.next.key, p.next.mouse
+		p.advance()
+	}
+
+	if key != nil {
+		*key = k
+	}
+	if mouse != nil {
+		*mouse = p.mouse
+	}
+	return event
+}
+
+func (p *Replay) NextFrame() {
+	p.frame++
+}
+
+// Done reports whether every recorded event has been played back.
+func (p *Replay) Done() bool {
+	return p.next == nil
+}
+
+// Err returns the error that stopped the replay early, if any.
+func (p *Replay) Err() error {
+	return p.err
+}
+
+// advance reads the next entry of the recording.
+func (p *Replay) advance() {
+	last := uint64(0)
+	if p.next != nil {
+		last = p.next.frame
+	}
+
+	entry, err := p.readEntry(last)
+	if err == io.EOF {
+		p.next = nil
+		return
+	}
+	if err != nil {
+		if err == io.ErrUnexpectedEOF {
+			err = errors.New("tcod: input recording is truncated")
+		}
+		p.next, p.err = nil, err
+		return
+	}
+	p.next = entry
+}
+
+func (p *Replay) readEntry(last uint64) (*recordedEntry, error) {
+	delta, err := binary.ReadUvarint(p.r)
+	if err != nil {
+		return nil, err
+	}
+	entry := &recordedEntry{frame: last + delta}
+
+	d := &recordingDecoder{r: p.r}
+
+	switch tag := d.byte(); tag {
+	case recKeyDown:
+		key, repeat := d.key()
+		entry.event = KeyDown{key, repeat}
+	case recKeyUp:
+		key, _ := d.key()
+		entry.event = KeyUp{key}
+	case recTextInput:
+		entry.event = TextInput{d.string()}
+	case recMouseMotion:
+		v := d.varints(6)
+		entry.event = MouseMotion{v[0], v[1], v[2], v[3], v[4], v[5]}
+	case recMouseButton:
+		v := d.varints(6)
+		entry.event = MouseButton{MouseButtonID(v[0]), flag(d.byte(), 0), v[1], v[2], v[3], v[4], v[5]}
+	case recMouseWheel:
+		v := d.varints(2)
+		entry.event = MouseWheel{v[0], v[1]}
+	case recWindowResized:
+		v := d.varints(2)
+		entry.event = WindowResized{v[0], v[1]}
+	case recWindowFocus:
+		entry.event = WindowFocus{flag(d.byte(), 0)}
+	case recQuit:
+		entry.event = Quit{}
+	case recCheck:
+		entry.check = Event(d.uvarint())
+		entry.key, _ = d.key()
+		entry.mouse = d.mouse()
+	default:
+		if d.err == nil {
+			d.err = errors.New("tcod: input recording is corrupt")
+		}
+	}
+
+	if d.err != nil {
+		// Running out of data part way through an entry means it's truncated.
+		if d.err == io.EOF {
+			return nil, io.ErrUnexpectedEOF
+		}
+		return nil, d.err
+	}
+	return entry, nil
+}
+
+// recordingDecoder reads the fields of an entry, remembering the first error so
+// an entry can be decoded without checking each field.
+type recordingDecoder struct {
+	r   *bufio.Reader
+	err error
+}
+
+func (d *recordingDecoder) byte() byte {
+	if d.err != nil {
+		return 0
+	}
+	var b byte
+	b, d.err = d.r.ReadByte()
+	return b
+}
+
+func (d *recordingDecoder) uvarint() uint64 {
+	if d.err != nil {
+		return 0
+	}
+	var v uint64
+	v, d.err = binary.ReadUvarint(d.r)
+	return v
+}
+
+func (d *recordingDecoder) varints(n int) []int {
+	result := make([]int, n)
+	for i := range result {
+		if d.err != nil {
+			break
+		}
+		var v int64
+		v, d.err = binary.ReadVarint(d.r)
+		result[i] = int(v)
+	}
+	return result
+}
+
+func (d *recordingDecoder) string() string {
+	n := d.uvarint()
+	if d.err != nil {
+		return ""
+	}
+	if n > 1<<16 {
+		d.err = errors.New("tcod: input recording is corrupt")
+		return ""
+	}
+	b := make([]byte, n)
+	_, d.err = io.ReadFull(d.r, b)
+	return string(b)
+}
+
+func (d *recordingDecoder) key() (key Key, repeat bool) {
+	key.VK = KeyCode(d.varints(1)[0])
+	key.C = d.byte()
+	f := d.byte()
+	key.Pressed, key.LAlt, key.LCtrl = flag(f, 0), flag(f, 1), flag(f, 2)
+	key.RAlt, key.RCtrl, key.Shift = flag(f, 3), flag(f, 4), flag(f, 5)
+	return key, flag(f, 6)
+}
+
+func (d *recordingDecoder) mouse() (m Mouse) {
+	v := d.varints(8)
+	m.X, m.Y, m.Dx, m.Dy, m.Cx, m.Cy, m.Dcx, m.Dcy = v[0], v[1], v[2], v[3], v[4], v[5], v[6], v[7]
+	f := d.byte()
+	m.LButton, m.RButton, m.MButton = flag(f, 0), flag(f, 1), flag(f, 2)
+	m.LButtonPressed, m.RButtonPressed, m.MButtonPressed = flag(f, 3), flag(f, 4), flag(f, 5)
+	m.WheelUp, m.WheelDown = flag(f, 6), flag(f, 7)
+	return
+}
@@ -0,0 +1,126 @@
+package tcod
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/sbowman/tcod/tcod/keys"
+)
+
+// scriptedInput is an InputSource that returns a fixed list of events for each
+// frame, and moves the mouse one cell right every frame.
+type scriptedInput struct {
+	frames [][]InputEvent
+	frame  int
+}
+
+func (in *scriptedInput) PollEvent() InputEvent {
+	if in.frame >= len(in.frames) || len(in.frames[in.frame]) == 0 {
+		return nil
+	}
+	ev := in.frames[in.frame][0]
+	in.frames[in.frame] = in.frames[in.frame][1:]
+	return ev
+}
+
+func (in *scriptedInput) CheckForEvent(eventMask int, key *Key, mouse *Mouse) Event {
+	if mouse != nil {
+		*mouse = Mouse{Cx: in.frame % 20, Cy: 3, X: in.frame % 20 * 8, Y: 24}
+	}
+	if key != nil {
+		*key = Key{}
+	}
+	return EventMouseMove
+}
+
+func (in *scriptedInput) NextFrame() {
+	in.frame++
+}
+
+// playFrames runs a little game loop for n frames, drawing everything it reads
+// from in, along with some random noise, onto console.
+func playFrames(in InputSource, console IConsole, seed uint32, n int) {
+	random := NewRandomFromSeed(seed)
+	px, py := 10, 10
+	for frame := 0; frame < n; frame++ {
+		for ev := in.PollEvent(); ev != nil; ev = in.PollEvent() {
+			switch ev := ev.(type) {
+			case KeyDown:
+				switch ev.VK {
+				case keys.Up:
+					py--
+				case keys.Down:
+					py++
+				case keys.Left:
+					px--
+				case keys.Right:
+					px++
+				}
+				console.SetChar(px, py, '@')
+			case TextInput:
+				console.Print(px, py+1, "%s", ev.Text)
+			case MouseButton:
+				color := Color{uint8(random.GetInt(0, 255)), uint8(random.GetInt(0, 255)), 0}
+				console.SetCharBackground(ev.Cx, ev.Cy, color, BkgndSet)
+			}
+		}
+
+		var mouse Mouse
+		in.CheckForEvent(EventMouse, nil, &mouse)
+		console.SetChar(mouse.Cx, mouse.Cy, '+')
+		console.SetCharForeground(random.GetInt(0, 39), random.GetInt(0, 24),
+			Color{0, uint8(random.GetInt(0, 255)), uint8(random.GetInt(0, 255))})
+		in.NextFrame()
+	}
+}
+
+func TestRecordReplay(t *testing.T) {
+	script := &scriptedInput{frames: [][]InputEvent{
+		{KeyDown{Key: Key{VK: keys.Right, Pressed: true}}},
+		{},
+		{KeyDown{Key: Key{VK: keys.Down, Pressed: true}}, KeyUp{Key{VK: keys.Down}}},
+		{TextInput{"hello"}},
+		{},
+		{MouseButton{Button: ButtonLeft, Pressed: true, Clicks: 1, X: 40, Y: 48, Cx: 5, Cy: 6}},
+		{KeyDown{Key: Key{VK: keys.Right, Pressed: true}, Repeat: true}, WindowFocus{true}},
+		{},
+		{MouseButton{Button: ButtonLeft, Pressed: true, Clicks: 2, X: 72, Y: 16, Cx: 9, Cy: 2}},
+		{Quit{}},
+	}}
+
+	const seed, frames = 1234, 12
+	var recording bytes.Buffer
+	recorder, err := NewRecorder(&recording, script, seed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	recorded := NewHeadlessConsole(40, 25)
+	playFrames(recorder, recorded, seed, frames)
+	if err := recorder.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	replay, err := NewReplay(&recording)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if replay.Seed() != seed {
+		t.Errorf("replay seed is %d, want %d", replay.Seed(), seed)
+	}
+	replayed := NewHeadlessConsole(40, 25)
+	playFrames(replay, replayed, replay.Seed(), frames)
+	if err := replay.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if !replay.Done() {
+		t.Error("replay has events left over")
+	}
+
+	if reflect.DeepEqual(recorded.Tiles(), NewHeadlessConsole(40, 25).Tiles()) {
+		t.Fatal("the recorded session drew nothing")
+	}
+	if !reflect.DeepEqual(recorded.Tiles(), replayed.Tiles()) {
+		t.Errorf("replay drew\n%s\nrecorded session drew\n%s", replayed, recorded)
+	}
+}
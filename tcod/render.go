@@ -0,0 +1,65 @@
+package tcod
+
+/*
+ #cgo LDFLAGS: -lSDL2
+ #include <stdint.h>
+ #include "include/libtcod.h"
+
+ // The leading fields of SDL2's SDL_Surface, which are part of SDL2's stable ABI.
+ // Declaring them here saves depending on the SDL2 development headers.
+ typedef struct {
+   uint32_t flags;
+   void *format;
+   int w, h;
+   int pitch;
+   void *pixels;
+ } _SDL_Surface;
+
+ extern void SDL_FreeSurface(struct SDL_Surface *surface);
+*/
+import "C"
+
+import (
+	"errors"
+	"image"
+	"runtime"
+	"unsafe"
+)
+
+//
+// Offscreen rendering
+//
+
+// RenderConsoleToImage draws con with the tiles from ts using libtcod's software
+// renderer, and returns the result as an image.  No window is needed, so it works
+// in headless environments where SysSaveScreenshot can't.
+func RenderConsoleToImage(con *Console, ts *Tileset) (*image.RGBA, error) {
+	if con.Data == nil {
+		return nil, errors.New("tcod: the root console can't be rendered offscreen")
+	}
+	if ts == nil {
+		return nil, errors.New("tcod: a tileset is required to render a console")
+	}
+
+	var surface *C.struct_SDL_Surface
+	err := toError(C.TCOD_tileset_render_to_surface(ts.Data, con.Data, nil, &surface))
+	runtime.KeepAlive(con)
+	runtime.KeepAlive(ts)
+	if surface != nil {
+		defer C.SDL_FreeSurface(surface)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// The surface is RGBA32, so rows can be copied as they are.
+	s := (*C._SDL_Surface)(unsafe.Pointer(surface))
+	w, h, pitch := int(s.w), int(s.h), int(s.pitch)
+	pixels := C.GoBytes(s.pixels, C.int(pitch*h))
+
+	result := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		copy(result.Pix[y*result.Stride:y*result.Stride+w*4], pixels[y*pitch:])
+	}
+	return result, nil
+}
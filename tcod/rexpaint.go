@@ -0,0 +1,205 @@
+package tcod
+
+/*
+ #include <stdlib.h>
+ #include "include/libtcod.h"
+*/
+import "C"
+
+import (
+	"compress/gzip"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"runtime"
+	"unsafe"
+)
+
+//
+// REXPaint
+//
+
+// XPKeyColor is the background color REXPaint uses for transparent cells.  Layers
+// loaded from .xp files have it set as their key color, so blitting a layer onto
+// another only draws the cells the artist painted.
+var XPKeyColor = Color{255, 0, 255}
+
+// xpVersion is the format version written at the start of .xp files.
+const xpVersion = -1
+
+// xpCompressionLevel is the gzip level .xp files are written with, zlib's usual
+// trade between size and speed.
+const xpCompressionLevel = 6
+
+// xpMaxLayers is the most layers REXPaint supports.
+const xpMaxLayers = 9
+
+// xpMaxSize and xpMaxCells limit the size of the layers read by LoadXPFrom, so a
+// corrupt header can't make it allocate gigabytes before the data runs out.
+const (
+	xpMaxSize  = 4096
+	xpMaxCells = 1 << 22
+)
+
+// LoadXP loads every layer of a REXPaint .xp file, bottom layer first.
+func LoadXP(filename string) ([]*Console, error) {
+	cfilename := C.CString(filename)
+	defer C.free(unsafe.Pointer(cfilename))
+
+	l := C.TCOD_console_list_from_xp(cfilename)
+	if l == nil {
+		return nil, errors.New(C.GoString(C.TCOD_get_error()))
+	}
+	defer C.TCOD_list_delete(l)
+
+	size := int(C.TCOD_list_size(l))
+	result := make([]*Console, size)
+	for i := 0; i < size; i++ {
+		console := &Console{C.TCOD_console_t(C.TCOD_list_get(l, C.int(i)))}
+		runtime.SetFinalizer(console, deleteConsole)
+		console.SetKeyColor(XPKeyColor)
+		result[i] = console
+	}
+	return result, nil
+}
+
+// SaveXP saves consoles as the layers of a REXPaint .xp file, bottom layer first.
+func SaveXP(filename string, consoles ...*Console) error {
+	if len(consoles) == 0 {
+		return errors.New("tcod: no consoles to save")
+	}
+	cfilename := C.CString(filename)
+	defer C.free(unsafe.Pointer(cfilename))
+
+	l := C.TCOD_list_new()
+	defer C.TCOD_list_delete(l)
+	for _, console := range consoles {
+		if console.Data == nil {
+			return errors.New("tcod: the root console can't be saved as a layer")
+		}
+		C.TCOD_list_push(l, unsafe.Pointer(console.Data))
+	}
+
+	ok := toBool(C.TCOD_console_list_save_xp(l, cfilename, xpCompressionLevel))
+	runtime.KeepAlive(consoles)
+	if !ok {
+		return errors.New(C.GoString(C.TCOD_get_error()))
+	}
+	return nil
+}
+
+// LoadXPFrom reads every layer of REXPaint .xp data from r, bottom layer first.
+// The data is gzip compressed, as REXPaint writes it.
+func LoadXPFrom(r io.Reader) ([]*Console, error) {
+	zr, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+
+	var header struct{ Version, Layers int32 }
+	if err := binary.Read(zr, binary.LittleEndian, &header); err != nil {
+		return nil, err
+	}
+	if header.Version != xpVersion {
+		return nil, fmt.Errorf("tcod: unsupported REXPaint version %d", header.Version)
+	}
+	if header.Layers <= 0 || header.Layers > xpMaxLayers {
+		return nil, fmt.Errorf("tcod: bad REXPaint layer count %d", header.Layers)
+	}
+
+	result := make([]*Console, 0, header.Layers)
+	for i := 0; i < int(header.Layers); i++ {
+		console, err := readXPLayer(zr)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, console)
+	}
+	return result, nil
+}
+
+// xpCell is a single cell of a .xp layer.
+type xpCell struct {
+	Ch     uint32
+	Fg, Bg Color
+}
+
+func readXPLayer(r io.Reader) (*Console, error) {
+	var size struct{ W, H int32 }
+	if err := binary.Read(r, binary.LittleEndian, &size); err != nil {
+		return nil, err
+	}
+	if size.W <= 0 || size.H <= 0 || size.W > xpMaxSize || size.H > xpMaxSize ||
+		int64(size.W)*int64(size.H) > xpMaxCells {
+		return nil, fmt.Errorf("tcod: bad REXPaint layer size %dx%d", size.W, size.H)
+	}
+
+	w, h := int(size.W), int(size.H)
+	cells := make([]xpCell, w*h)
+	if err := binary.Read(r, binary.LittleEndian, cells); err != nil {
+		return nil, err
+	}
+
+	// Cells are stored column by column.
+	tiles := make([]Tile, w*h)
+	for i, cell := range cells {
+		x, y := i/h, i%h
+		tiles[y*w+x] = NewTile(rune(cell.Ch), cell.Fg, cell.Bg)
+	}
+
+	console := NewConsole(w, h)
+	console.SetTiles(0, 0, w, tiles)
+	console.SetKeyColor(XPKeyColor)
+	return console, nil
+}
+
+// SaveXPTo writes consoles to w as gzip compressed REXPaint .xp data, bottom layer
+// first.  Cells whose background matches a console's key color are written with
+// XPKeyColor, so they stay transparent in REXPaint.
+func SaveXPTo(w io.Writer, consoles ...*Console) error {
+	if len(consoles) == 0 {
+		return errors.New("tcod: no consoles to save")
+	}
+
+	zw, err := gzip.NewWriterLevel(w, xpCompressionLevel)
+	if err != nil {
+		return err
+	}
+	header := struct{ Version, Layers int32 }{xpVersion, int32(len(consoles))}
+	if err := binary.Write(zw, binary.LittleEndian, header); err != nil {
+		return err
+	}
+	for _, console := range consoles {
+		if console.Data == nil {
+			return errors.New("tcod: the root console can't be saved as a layer")
+		}
+		if err := writeXPLayer(zw, console); err != nil {
+			return err
+		}
+	}
+	return zw.Close()
+}
+
+func writeXPLayer(w io.Writer, console *Console) error {
+	width, height := console.GetWidth(), console.GetHeight()
+	tiles := console.Tiles()
+	keyColor := console.getKeyColor()
+
+	cells := make([]xpCell, width*height)
+	for i := range cells {
+		x, y := i/height, i%height
+		tile := tiles[y*width+x]
+		cells[i] = xpCell{uint32(tile.Ch), tile.Fg, tile.Bg}
+		if keyColor != nil && tile.Bg == *keyColor {
+			cells[i].Bg = XPKeyColor
+		}
+	}
+
+	size := struct{ W, H int32 }{int32(width), int32(height)}
+	if err := binary.Write(w, binary.LittleEndian, size); err != nil {
+		return err
+	}
+	return binary.Write(w, binary.LittleEndian, cells)
+}
@@ -0,0 +1,129 @@
+package tcod
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// newXPLayer returns a w x h console with a different glyph and colors in every
+// cell, and a transparent cell at 1, 0.
+func newXPLayer(w, h int, seed uint8) *Console {
+	console := NewConsole(w, h)
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			v := seed + uint8(y*w+x)
+			console.PutCharEx(x, y, rune('a'+int(v)%26), Color{v, v * 2, v * 3}, Color{v * 5, v, 7})
+		}
+	}
+	console.SetCharBackground(1, 0, XPKeyColor, BkgndSet)
+	console.SetKeyColor(XPKeyColor)
+	return console
+}
+
+func sameLayers(t *testing.T, name string, got, want []*Console) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Fatalf("%s: %d layers, want %d", name, len(got), len(want))
+	}
+	for i := range want {
+		if got[i].GetWidth() != want[i].GetWidth() || got[i].GetHeight() != want[i].GetHeight() {
+			t.Errorf("%s: layer %d is %dx%d, want %dx%d", name, i, got[i].GetWidth(), got[i].GetHeight(),
+				want[i].GetWidth(), want[i].GetHeight())
+			continue
+		}
+		gotTiles, wantTiles := got[i].Tiles(), want[i].Tiles()
+		for j := range wantTiles {
+			if gotTiles[j] != wantTiles[j] {
+				t.Errorf("%s: layer %d cell %d is %+v, want %+v", name, i, j, gotTiles[j], wantTiles[j])
+				break
+			}
+		}
+		if key := got[i].getKeyColor(); key == nil || *key != XPKeyColor {
+			t.Errorf("%s: layer %d key color is %v, want %v", name, i, key, XPKeyColor)
+		}
+	}
+}
+
+func TestXPRoundTrip(t *testing.T) {
+	layers := []*Console{newXPLayer(5, 3, 0), newXPLayer(5, 3, 100)}
+
+	var buf bytes.Buffer
+	if err := SaveXPTo(&buf, layers...); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := LoadXPFrom(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sameLayers(t, "SaveXPTo", loaded, layers)
+
+	// libtcod reads what SaveXPTo writes, and the other way around.
+	filename := filepath.Join(t.TempDir(), "layers.xp")
+	if err := SaveXP(filename, layers...); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err = LoadXP(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sameLayers(t, "SaveXP", loaded, layers)
+
+	buf.Reset()
+	if err := SaveXPTo(&buf, loaded...); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err = LoadXPFrom(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sameLayers(t, "SaveXP then SaveXPTo", loaded, layers)
+}
+
+// xpData gzips a .xp header followed by the given layer data.
+func xpData(version, layers int32, data ...interface{}) []byte {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	binary.Write(zw, binary.LittleEndian, []int32{version, layers})
+	for _, d := range data {
+		binary.Write(zw, binary.LittleEndian, d)
+	}
+	zw.Close()
+	return buf.Bytes()
+}
+
+func TestLoadXPFromErrors(t *testing.T) {
+	cell := xpCell{'a', White, Black}
+	tests := []struct {
+		name string
+		data []byte
+		err  string
+	}{
+		{"not gzip", []byte("not an xp file"), ""},
+		{"version", xpData(2, 1, []int32{1, 1}, cell), "version 2"},
+		{"no layers", xpData(xpVersion, 0), "layer count 0"},
+		{"negative layers", xpData(xpVersion, -1), "layer count -1"},
+		{"too many layers", xpData(xpVersion, xpMaxLayers+1), "layer count 10"},
+		{"huge layer count", xpData(xpVersion, 1<<30), "layer count"},
+		{"zero width", xpData(xpVersion, 1, []int32{0, 5}), "layer size 0x5"},
+		{"negative height", xpData(xpVersion, 1, []int32{5, -5}), "layer size 5x-5"},
+		{"too wide", xpData(xpVersion, 1, []int32{xpMaxSize + 1, 1}), "layer size"},
+		{"too many cells", xpData(xpVersion, 1, []int32{xpMaxSize, xpMaxSize}), "layer size"},
+		{"missing layer", xpData(xpVersion, 2, []int32{1, 1}, cell), ""},
+		{"truncated cells", xpData(xpVersion, 1, []int32{2, 1}, cell), ""},
+		{"truncated header", xpData(xpVersion, 1, int16(1)), ""},
+	}
+	for _, test := range tests {
+		layers, err := LoadXPFrom(bytes.NewReader(test.data))
+		if err == nil {
+			t.Errorf("%s: loaded %d layers", test.name, len(layers))
+			continue
+		}
+		if !strings.Contains(err.Error(), test.err) {
+			t.Errorf("%s: error is %q, want one containing %q", test.name, err, test.err)
+		}
+	}
+}
@@ -10,37 +10,55 @@ package tcod
 
 /*
  #cgo LDFLAGS:-ltcod
+ #include <stdint.h>
  #include <stdio.h>
  #include <stdlib.h>
  #include <string.h>
  #include "include/libtcod.h"
 
+ // Path cost functions are written in Go.  libtcod calls _path_cost, which passes
+ // the call on to goPathCost with the handle of the Go function as user data.
+ extern float goPathCost(int xFrom, int yFrom, int xTo, int yTo, uintptr_t handle);
+
+ float _path_cost(int xFrom, int yFrom, int xTo, int yTo, void *data) {
+ 	return goPathCost(xFrom, yFrom, xTo, yTo, (uintptr_t)data);
+ }
+
+ TCOD_path_t _TCOD_path_new_using_function(int w, int h, uintptr_t handle, float diagonalCost) {
+ 	return TCOD_path_new_using_function(w, h, _path_cost, (void *)handle, diagonalCost);
+ }
+
+ TCOD_dijkstra_t _TCOD_dijkstra_new_using_function(int w, int h, uintptr_t handle, float diagonalCost) {
+ 	return TCOD_dijkstra_new_using_function(w, h, _path_cost, (void *)handle, diagonalCost);
+ }
+
  // This is a workaround for cgo disability to process varargs
- // These functions are copied verbatim from console_c and replaced ... with simple string
+ // These functions wrap libtcod's UTF-8 printing functions, passing the string through "%s"
  // Formatting will be done in Go functions
 
  void _TCOD_console_print(TCOD_console_t con,int x, int y, char *s) {
- 	TCOD_console_print(con,x,y,"%s",s);
+ 	TCOD_console_printf(con,x,y,"%s",s);
  }
 
  void  _TCOD_console_print_ex(TCOD_console_t con,int x, int y, TCOD_bkgnd_flag_t flag, TCOD_alignment_t alignment, const char *s) {
- 	TCOD_console_print_ex(con,x,y,flag,alignment,"%s",s);
+ 	TCOD_console_printf_ex(con,x,y,flag,alignment,"%s",s);
  }
 
  int _TCOD_console_print_rect(TCOD_console_t con,int x, int y, int w, int h, char *s) {
- 	return TCOD_console_print_rect(con,x,y,w,h,"%s",s);
+ 	return TCOD_console_printf_rect(con,x,y,w,h,"%s",s);
  }
 
 
  int _TCOD_console_print_rect_ex(TCOD_console_t con,int x, int y, int w, int h, TCOD_bkgnd_flag_t flag, TCOD_alignment_t alignment, const char *s) {
-	return TCOD_console_print_rect_ex(con, x, y, w, h, flag, alignment, "%s", s);
+	return TCOD_console_printf_rect_ex(con, x, y, w, h, flag, alignment, "%s", s);
  }
 
  int _TCOD_console_height_rect(TCOD_console_t con,int x, int y, int w, int h, char *s) {
- 	return TCOD_console_get_height_rect(con,x,y,w,h, "%s", s);
+ 	return TCOD_console_get_height_rect_fmt(con,x,y,w,h, "%s", s);
  }
 
- void _TCOD_console_print_frame(TCOD_console_t con,int x,int y,int w,int h, bool empty, TCOD_bkgnd_flag_t flag, char *s) {
+ // slen is the length of s in runes, since s is UTF-8
+ void _TCOD_console_print_frame(TCOD_console_t con,int x,int y,int w,int h, bool empty, TCOD_bkgnd_flag_t flag, char *s, int slen) {
  	TCOD_console_put_char(con,x,y,TCOD_CHAR_NW,flag);
  	TCOD_console_put_char(con,x+w-1,y,TCOD_CHAR_NE,flag);
  	TCOD_console_put_char(con,x,y+h-1,TCOD_CHAR_SW,flag);
@@ -58,13 +76,13 @@ package tcod
  	if (s) {
  		int xs;
  		TCOD_color_t tmp;
- 		xs = x + (w-strlen(s)-2)/2;
+ 		xs = x + (w-slen-2)/2;
 
 		tmp = TCOD_console_get_default_background(con);
 		TCOD_console_set_default_background(con, TCOD_console_get_default_foreground(con));
 		TCOD_console_set_default_foreground(con, tmp);
 
- 		TCOD_console_print(con,xs,y," %s ",s);
+ 		TCOD_console_printf(con,xs,y," %s ",s);
 
 		tmp = TCOD_console_get_default_background(con);
 		TCOD_console_set_default_background(con, TCOD_console_get_default_foreground(con));
@@ -95,9 +113,13 @@ package tcod
 import "C"
 
 import (
+	"errors"
 	"fmt"
+	"math"
 	"runtime"
 	"unsafe"
+
+	"github.com/sbowman/tcod/tcod/headless"
 )
 
 type void unsafe.Pointer
@@ -161,6 +183,9 @@ func toStringSlice(l C.TCOD_list_t, free bool) (result []string) {
 // Event is a system event, such as a keypress, mouse movement, or finger tap.
 type Event int
 
+// SysCheckForEvent fills in key and mouse from the next pending event.  Prefer
+// PollEvent, which returns typed events and includes window and text input
+// events.  The two read from the same queue, so don't mix them.
 func SysCheckForEvent(eventMask int, key *Key, mouse *Mouse) Event {
 	var cKey C.TCOD_key_t
 	var cMouse C.TCOD_mouse_t
@@ -232,6 +257,20 @@ func fromKey(k Key) (result C.TCOD_key_t) {
 	return
 }
 
+//
+//
+// Error handling
+//
+
+// toError converts a libtcod error code into a Go error, using the message libtcod
+// recorded for the failure.  Warnings are not treated as errors.
+func toError(code C.TCOD_Error) error {
+	if code >= C.TCOD_E_OK {
+		return nil
+	}
+	return errors.New(C.GoString(C.TCOD_get_error()))
+}
+
 //
 //
 // Bool handling
@@ -244,6 +283,15 @@ func fromBool(b bool) C.bool {
 	return C.bool(b)
 }
 
+// fromInts converts Go ints, which may be 64 bits, into a C int array.
+func fromInts(values []int) []C.int {
+	result := make([]C.int, len(values))
+	for i, v := range values {
+		result[i] = C.int(v)
+	}
+	return result
+}
+
 //
 // Color handling
 //
@@ -505,9 +553,9 @@ type IConsole interface {
 	GetCharForeground(x, y int) Color
 	SetCharBackground(x, y int, color Color, flag BkgndFlag)
 	SetCharForeground(x, y int, color Color)
-	SetChar(x, y int, c int)
-	PutChar(x, y, c int, flag BkgndFlag)
-	PutCharEx(x, y, c int, fore, back Color)
+	SetChar(x, y int, c rune)
+	PutChar(x, y int, c rune, flag BkgndFlag)
+	PutCharEx(x, y int, c rune, fore, back Color)
 	Print(x, y int, fmts string, v ...interface{})
 	PrintEx(x, y int, flag BkgndFlag, alignment Alignment, fmts string, v ...interface{})
 	PrintRect(x, y, w, h int, fmts string, v ...interface{}) int
@@ -521,7 +569,7 @@ type IConsole interface {
 	Hline(x, y, l int, flag BkgndFlag)
 	Vline(x, y, l int, flag BkgndFlag)
 	PrintFrame(x, y, w, h int, empty bool, flag BkgndFlag, fmts string, v ...interface{})
-	GetChar(x, y int) int
+	GetChar(x, y int) rune
 	GetWidth() int
 	GetHeight() int
 	SetKeyColor(color Color)
@@ -570,15 +618,15 @@ func (console *Console) SetCharForeground(x, y int, color Color) {
 	C.TCOD_console_set_char_foreground(console.Data, C.int(x), C.int(y), ccolor)
 }
 
-func (console *Console) SetChar(x, y int, c int) {
+func (console *Console) SetChar(x, y int, c rune) {
 	C.TCOD_console_set_char(console.Data, C.int(x), C.int(y), C.int(c))
 }
 
-func (console *Console) PutChar(x, y, c int, flag BkgndFlag) {
+func (console *Console) PutChar(x, y int, c rune, flag BkgndFlag) {
 	C.TCOD_console_put_char(console.Data, C.int(x), C.int(y), C.int(c), C.TCOD_bkgnd_flag_t(flag))
 }
 
-func (console *Console) PutCharEx(x, y, c int, fore, back Color) {
+func (console *Console) PutCharEx(x, y int, c rune, fore, back Color) {
 	forec := fromColor(fore)
 	backc := fromColor(back)
 	C.TCOD_console_put_char_ex(console.Data, C.int(x), C.int(y), C.int(c), forec, backc)
@@ -653,23 +701,10 @@ func (console *Console) PrintFrame(x, y, w, h int, empty bool, flag BkgndFlag, f
 	cs := C.CString(s)
 	defer C.free(unsafe.Pointer(cs))
 	C._TCOD_console_print_frame(console.Data, C.int(x), C.int(y), C.int(w), C.int(h),
-		fromBool(empty), C.TCOD_bkgnd_flag_t(flag), cs)
+		fromBool(empty), C.TCOD_bkgnd_flag_t(flag), cs, C.int(headless.VisibleLength(s)))
 
 }
 
-// TODO check unicode support
-// TCODLIB_API void TCOD_console_map_string_to_font_utf(const wchar_t *s, int fontCharX, int fontCharY);
-// TCODLIB_API void TCOD_console_print_left_utf(TCOD_console_t con,int x, int y, TCOD_bkgnd_flag_t flag, const wchar_t *fmt, ...);
-// TCODLIB_API void TCOD_console_print_right_utf(TCOD_console_t con,int x, int y, TCOD_bkgnd_flag_t flag, const wchar_t *fmt, ...);
-// TCODLIB_API void TCOD_console_print_center_utf(TCOD_console_t con,int x, int y, TCOD_bkgnd_flag_t flag, const wchar_t *fmt, ...);
-// TCODLIB_API int TCOD_console_print_left_rect_utf(TCOD_console_t con,int x, int y, int w, int h, TCOD_bkgnd_flag_t flag, const wchar_t *fmt, ...);
-// TCODLIB_API int TCOD_console_print_right_rect_utf(TCOD_console_t con,int x, int y, int w, int h, TCOD_bkgnd_flag_t flag, const wchar_t *fmt, ...);
-// TCODLIB_API int TCOD_console_print_center_rect_utf(TCOD_console_t con,int x, int y, int w, int h, TCOD_bkgnd_flag_t flag, const wchar_t *fmt, ...);
-// TCODLIB_API int TCOD_console_height_left_rect_utf(TCOD_console_t con,int x, int y, int w, int h, const wchar_t *fmt, ...);
-// TCODLIB_API int TCOD_console_height_right_rect_utf(TCOD_console_t con,int x, int y, int w, int h, const wchar_t *fmt, ...);
-// TCODLIB_API int TCOD_console_height_center_rect_utf(TCOD_console_t con,int x, int y, int w, int h, const wchar_t *fmt, ...);
-// #endif
-
 func (console *Console) GetDefaultBackground() Color {
 	return toColor(C.TCOD_console_get_default_background(console.Data))
 }
@@ -686,8 +721,8 @@ func (console *Console) GetCharForeground(x, y int) Color {
 	return toColor(C.TCOD_console_get_char_foreground(console.Data, C.int(x), C.int(y)))
 }
 
-func (console *Console) GetChar(x, y int) int {
-	return int(C.TCOD_console_get_char(console.Data, C.int(x), C.int(y)))
+func (console *Console) GetChar(x, y int) rune {
+	return rune(C.TCOD_console_get_char(console.Data, C.int(x), C.int(y)))
 }
 
 func (console *Console) GetWidth() int {
@@ -703,7 +738,21 @@ func (console *Console) SetKeyColor(color Color) {
 	C.TCOD_console_set_key_color(console.Data, ccolor)
 }
 
+// getKeyColor returns the console's key color, or nil if it doesn't have one.
+func (console *Console) getKeyColor() *Color {
+	if console.Data == nil || !toBool(console.Data.has_key_color) {
+		return nil
+	}
+	color := toColor(console.Data.key_color)
+	return &color
+}
+
 func (console *Console) Blit(xSrc, ySrc, wSrc, hSrc int, dst IConsole, xDst, yDst int, foregroundAlpha, backgroundAlpha float32) {
+	if _, ok := dst.(*HeadlessConsole); ok {
+		headless.Blit(toGrid(console), (*headless.Color)(console.getKeyColor()), xSrc, ySrc, wSrc, hSrc, toGrid(dst), xDst, yDst,
+			foregroundAlpha, backgroundAlpha)
+		return
+	}
 	C.TCOD_console_blit(console.Data, C.int(xSrc), C.int(ySrc), C.int(wSrc), C.int(hSrc),
 		dst.GetData(), C.int(xDst), C.int(yDst), C.float(foregroundAlpha), C.float(backgroundAlpha))
 }
@@ -759,6 +808,12 @@ func (root *RootConsole) SetCustomFont(fontFile string, flags int, nbCharHoriz i
 	C.TCOD_console_set_custom_font(cfontFile, C.int(flags), C.int(nbCharHoriz), C.int(nbCharVertic))
 }
 
+// SetTileset replaces the font used by the root console with tileset, as an
+// alternative to SetCustomFont.  libtcod holds its own reference to the tileset.
+func (root *RootConsole) SetTileset(tileset *Tileset) {
+	C.TCOD_set_default_tileset(tileset.Data)
+}
+
 func (root *RootConsole) MapAsciiCodeToFont(asciiCode, fontCharX, fontCharY int) {
 	C.TCOD_console_map_ascii_code_to_font(C.int(asciiCode), C.int(fontCharX), C.int(fontCharY))
 }
@@ -953,12 +1008,14 @@ func LinePoints(xo, yo, xd, yd int) []Point {
 	return result
 }
 
-//
 //
 // Name generator
 //
-
+// These share libtcod's global set of syllable sets; see NameGenerator for
+// generators that keep their own.
 //
+
+// NamegenParse reads the syllable sets in filename into libtcod's global set.
 func NamegenParse(filename string, random *Random) {
 	cfilename := C.CString(filename)
 	defer C.free(unsafe.Pointer(cfilename))
@@ -1546,6 +1603,27 @@ func (heightMap *HeightMap) SetNthValue(nth int, value float32) {
 	C._TCOD_heightmap_set_nth_value(heightMap.Data, C.int(nth), C.float(value))
 }
 
+// Values returns the heightmap's values in row-major order, as a slice backed by
+// libtcod's own buffer.  Changes to the slice are changes to the heightmap, so Go
+// filters can work on it without a cgo call per cell.  The slice must not be used
+// after the heightmap is deleted, so keep a reference to the heightmap for as
+// long as the slice is in use.
+func (heightMap *HeightMap) Values() []float32 {
+	n := int(heightMap.Data.w * heightMap.Data.h)
+	return (*[1 << 28]float32)(unsafe.Pointer(heightMap.Data.values))[:n:n]
+}
+
+// GetValues copies the heightmap's values into a new slice, in row-major order.
+func (heightMap *HeightMap) GetValues() []float32 {
+	return append([]float32(nil), heightMap.Values()...)
+}
+
+// SetValues copies values, in row-major order, into the heightmap.  Values past
+// the end of the heightmap are ignored.
+func (heightMap *HeightMap) SetValues(values []float32) {
+	copy(heightMap.Values(), values)
+}
+
 func (heightMap *HeightMap) GetSlope(x, y int) float32 {
 	return float32(C.TCOD_heightmap_get_slope(heightMap.Data, C.int(x), C.int(y)))
 }
@@ -1620,25 +1698,124 @@ func (heightMap *HeightMap) DigHill(hx, hy, hradius, hheight float32) {
 }
 
 func (heightMap *HeightMap) DigBezier(px, py *[4]int, startRadius, startDepth, endRadius, endDepth float32) {
-	C.TCOD_heightmap_dig_bezier(heightMap.Data,
-		(*C.int)(unsafe.Pointer(&px[0])),
-		(*C.int)(unsafe.Pointer(&py[0])),
+	cpx, cpy := fromInts(px[:]), fromInts(py[:])
+	C.TCOD_heightmap_dig_bezier(heightMap.Data, &cpx[0], &cpy[0],
 		C.float(startRadius), C.float(startDepth), C.float(endRadius), C.float(endDepth))
 }
 
+// DigLine digs a straight trench from x1, y1 to x2, y2, such as a river bed.
+func (heightMap *HeightMap) DigLine(x1, y1, x2, y2 int, radius, depth float32) {
+	px := [4]int{x1, x1 + (x2-x1)/3, x1 + (x2-x1)*2/3, x2}
+	py := [4]int{y1, y1 + (y2-y1)/3, y1 + (y2-y1)*2/3, y2}
+	heightMap.DigBezier(&px, &py, radius, depth, radius, depth)
+}
+
 func (heightMap *HeightMap) RainErosion(nbDrops int, erosionCoef, sedimentationCoef float32, rnd *Random) {
 	C.TCOD_heightmap_rain_erosion(heightMap.Data, C.int(nbDrops), C.float(erosionCoef), C.float(sedimentationCoef), rnd.Data)
 }
 
 func (heightMap *HeightMap) KernelTransform(kernelsize int, dx, dy []int, weight []float32, minLevel, maxLevel float32) {
+	cdx, cdy := fromInts(dx), fromInts(dy)
 	C.TCOD_heightmap_kernel_transform(heightMap.Data, C.int(kernelsize),
-		(*C.int)(unsafe.Pointer(&dx[0])),
-		(*C.int)(unsafe.Pointer(&dy[0])),
+		&cdx[0],
+		&cdy[0],
 		(*C.float)(unsafe.Pointer(&weight[0])),
 		C.float(minLevel),
 		C.float(maxLevel))
 }
 
+// Kernel is a weighted neighbourhood for HeightMap.ApplyKernel.  Each cell
+// becomes the weighted average of the cells at Dx, Dy from it.
+type Kernel struct {
+	Dx, Dy []int
+	Weight []float32
+}
+
+var (
+	// KernelBox averages each cell with its 8 neighbours.
+	KernelBox = Kernel{
+		Dx:     []int{-1, 0, 1, -1, 0, 1, -1, 0, 1},
+		Dy:     []int{-1, -1, -1, 0, 0, 0, 1, 1, 1},
+		Weight: []float32{1, 1, 1, 1, 1, 1, 1, 1, 1},
+	}
+
+	// KernelGaussian is a 3x3 approximation of a Gaussian blur.
+	KernelGaussian = Kernel{
+		Dx:     []int{-1, 0, 1, -1, 0, 1, -1, 0, 1},
+		Dy:     []int{-1, -1, -1, 0, 0, 0, 1, 1, 1},
+		Weight: []float32{1, 2, 1, 2, 4, 2, 1, 2, 1},
+	}
+
+	// KernelSharpen is a Laplacian sharpen, adding the difference between the
+	// cell and its orthogonal neighbours to the cell.
+	KernelSharpen = Kernel{
+		Dx:     []int{0, -1, 1, 0, 0},
+		Dy:     []int{-1, 0, 0, 1, 0},
+		Weight: []float32{-1, -1, -1, -1, 5},
+	}
+)
+
+// ApplyKernel runs KernelTransform with the kernel on the cells with values
+// between minLevel and maxLevel.
+func (heightMap *HeightMap) ApplyKernel(kernel Kernel, minLevel, maxLevel float32) {
+	heightMap.KernelTransform(len(kernel.Weight), kernel.Dx, kernel.Dy, kernel.Weight, minLevel, maxLevel)
+}
+
+// Blur smooths the whole heightmap with a Gaussian kernel.
+func (heightMap *HeightMap) Blur() {
+	heightMap.ApplyKernel(KernelGaussian, -math.MaxFloat32, math.MaxFloat32)
+}
+
+// Sharpen sharpens the whole heightmap with KernelSharpen.
+func (heightMap *HeightMap) Sharpen() {
+	heightMap.ApplyKernel(KernelSharpen, -math.MaxFloat32, math.MaxFloat32)
+}
+
+// MidPointDisplacement fills the heightmap with the diamond-square algorithm.
+// Roughness is usually around 0.5; higher values give more jagged terrain.  It
+// works best on heightmaps 2^n+1 cells wide and high.
+func (heightMap *HeightMap) MidPointDisplacement(rnd *Random, roughness float32) {
+	C.TCOD_heightmap_mid_point_displacement(heightMap.Data, rnd.Data, C.float(roughness))
+}
+
+// HeatErosion simulates thermal erosion, where material falls from steep
+// slopes.  On each pass, a cell more than minSlope above its lowest neighbour
+// loses erosionCoef of half the excess, so it never drops below the neighbour,
+// and aggregationCoef of that material settles on the neighbour; the rest is
+// lost.
+func (heightMap *HeightMap) HeatErosion(nbPass int, minSlope, erosionCoef, aggregationCoef float32) {
+	w, h := heightMap.GetWidth(), heightMap.GetHeight()
+	values := heightMap.Values()
+	for ; nbPass > 0; nbPass-- {
+		for y := 0; y < h; y++ {
+			for x := 0; x < w; x++ {
+				v := values[y*w+x]
+				lowest, low := -1, v
+				for _, dir := range heatErosionNeighbours {
+					nx, ny := x+dir[0], y+dir[1]
+					if nx >= 0 && ny >= 0 && nx < w && ny < h && values[ny*w+nx] < low {
+						lowest, low = ny*w+nx, values[ny*w+nx]
+					}
+				}
+				if lowest < 0 || v-low <= minSlope {
+					continue
+				}
+				// Move at most half the difference, so the cells don't swap places.
+				amount := erosionCoef * (v - low - minSlope) * 0.5
+				values[y*w+x] -= amount
+				values[lowest] += amount * aggregationCoef
+			}
+		}
+	}
+	runtime.KeepAlive(heightMap)
+}
+
+var heatErosionNeighbours = [8][2]int{
+	{-1, -1}, {0, -1}, {1, -1},
+	{-1, 0}, {1, 0},
+	{-1, 1}, {0, 1}, {1, 1},
+}
+
 func (heightMap *HeightMap) AddVoronoi(nbPoints, nbCoef int, coef []float32, rnd *Random) {
 	C.TCOD_heightmap_add_voronoi(heightMap.Data, C.int(nbPoints), C.int(nbCoef), (*C.float)(unsafe.Pointer(&coef[0])), rnd.Data)
 }
@@ -1773,31 +1950,46 @@ func (image *Image) IsPixelTransparent(x, y int) bool {
 //
 type Path struct {
 	Data C.TCOD_path_t
+	cost pathFuncHandle
 }
 
 func deletePath(path *Path) {
 	C.TCOD_path_delete(path.Data)
+	path.cost.release()
 }
 
 func NewPathUsingMap(m *Map, diagonalCost float32) *Path {
-	result := &Path{C.TCOD_path_new_using_map(m.Data, C.float(diagonalCost))}
+	result := &Path{Data: C.TCOD_path_new_using_map(m.Data, C.float(diagonalCost))}
 	runtime.SetFinalizer(result, deletePath)
 	return result
 }
 
-// Not implemented - go not supporting callbacks
-// func PathNewUsingFunction() {
-//	//TCODLIB_API TCOD_path_t
-//  TCOD_path_new_using_function(int map_width, int map_height, TCOD_path_func_t func, void *user_Data, float diagonalCost);
-// }
+// NewPathUsingFunc creates a path finder for a w x h map, where cost returns the
+// cost of moving between two adjacent cells.  A cost of 0 or less means the move
+// is blocked.
+func NewPathUsingFunc(w, h int, cost PathFunc, diagonalCost float32) *Path {
+	handle := registerPathFunc(cost)
+	result := &Path{
+		Data: C._TCOD_path_new_using_function(C.int(w), C.int(h), C.uintptr_t(handle), C.float(diagonalCost)),
+		cost: handle,
+	}
+	runtime.SetFinalizer(result, deletePath)
+	return result
+}
 
+// Compute finds a path from ox, oy to dx, dy.  Like Walk, it calls the path's
+// PathFunc, so the path is kept alive until it returns; otherwise its finalizer
+// could release the PathFunc part way through.
 func (path *Path) Compute(ox, oy, dx, dy int) bool {
-	return toBool(C.TCOD_path_compute(path.Data, C.int(ox), C.int(oy), C.int(dx), C.int(dy)))
+	result := toBool(C.TCOD_path_compute(path.Data, C.int(ox), C.int(oy), C.int(dx), C.int(dy)))
+	runtime.KeepAlive(path)
+	return result
 }
 
 func (path *Path) Walk(recalcWhenNeeded bool) (x, y int) {
 	var cx, cy C.int
 	C.TCOD_path_walk(path.Data, &cx, &cy, fromBool(recalcWhenNeeded))
+	runtime.KeepAlive(path)
 	x, y = int(cx), int(cy)
 	return
 }
@@ -1837,26 +2029,37 @@ func (path *Path) GetDestination() (x, y int) {
 
 type Dijkstra struct {
 	Data C.TCOD_dijkstra_t
+	cost pathFuncHandle
 }
 
 func deleteDijkstra(d *Dijkstra) {
 	C.TCOD_dijkstra_delete(d.Data)
+	d.cost.release()
 }
 
 func NewDijkstraUsingMap(m *Map, diagonalCost float32) *Dijkstra {
-	result := &Dijkstra{C.TCOD_dijkstra_new(m.Data, C.float(diagonalCost))}
+	result := &Dijkstra{Data: C.TCOD_dijkstra_new(m.Data, C.float(diagonalCost))}
 	runtime.SetFinalizer(result, deleteDijkstra)
 	return result
 }
 
-// Not implemented - go not supporting callbacks
-// func DijkstraNewUsingFunction() {
-//	//TCODLIB_API TCOD_Dijkstra_t
-//   TCOD_Dijkstra_new_using_function(int map_width, int map_height, TCOD_Dijkstra_func_t func, void *user_Data, float diagonalCost);
-// }
+// NewDijkstraUsingFunc creates a Dijkstra path finder for a w x h map, where cost
+// returns the cost of moving between two adjacent cells, as for NewPathUsingFunc.
+func NewDijkstraUsingFunc(w, h int, cost PathFunc, diagonalCost float32) *Dijkstra {
+	handle := registerPathFunc(cost)
+	result := &Dijkstra{
+		Data: C._TCOD_dijkstra_new_using_function(C.int(w), C.int(h), C.uintptr_t(handle), C.float(diagonalCost)),
+		cost: handle,
+	}
+	runtime.SetFinalizer(result, deleteDijkstra)
+	return result
+}
 
+// Compute finds the distance of every cell from rootX, rootY.  It calls the
+// PathFunc, if there is one, so the path finder is kept alive until it returns.
 func (dijkstra *Dijkstra) Compute(rootX, rootY int) {
 	C.TCOD_dijkstra_compute(dijkstra.Data, C.int(rootX), C.int(rootY))
+	runtime.KeepAlive(dijkstra)
 }
 
 func (dijkstra *Dijkstra) GetDistance(x, y int) float32 {
@@ -1899,6 +2102,11 @@ type Distribution C.TCOD_distribution_t
 
 type Random struct {
 	Data C.TCOD_random_t
+
+	// libtcod doesn't report these back, so they're kept here for the
+	// math/rand methods.
+	algo         RandomAlgo
+	distribution Distribution
 }
 
 type Dice struct {
@@ -1917,46 +2125,49 @@ func deleteRandom(r *Random) {
 	C.TCOD_random_delete(r.Data)
 }
 
-func newRandom(data C.TCOD_random_t) *Random {
-	result := &Random{data}
+func newRandom(data C.TCOD_random_t, algo RandomAlgo) *Random {
+	result := &Random{Data: data, algo: algo, distribution: DistributionLinear}
 	runtime.SetFinalizer(result, deleteRandom)
 	return result
 }
 
 func GetRandomInstance() *Random {
-	return newRandom(C.TCOD_random_get_instance())
+	return newRandom(C.TCOD_random_get_instance(), RNG_MT)
 }
 
 func NewRandom() *Random {
-	return newRandom(C.TCOD_random_new(C.TCOD_random_algo_t(RNG_MT)))
+	return newRandom(C.TCOD_random_new(C.TCOD_random_algo_t(RNG_MT)), RNG_MT)
 }
 
 func NewRandomWithAlgo(algo RandomAlgo) *Random {
-	return newRandom(C.TCOD_random_new(C.TCOD_random_algo_t(algo)))
+	return newRandom(C.TCOD_random_new(C.TCOD_random_algo_t(algo)), algo)
 }
 
 func NewRandomFromSeedWithAlgo(seed uint32, algo RandomAlgo) *Random {
-	return newRandom(C.TCOD_random_new_from_seed(C.TCOD_random_algo_t(algo), C.uint32_t(seed)))
+	return newRandom(C.TCOD_random_new_from_seed(C.TCOD_random_algo_t(algo), C.uint32_t(seed)), algo)
 }
 
 func NewRandomFromSeed(seed uint32) *Random {
 	return newRandom(
 		C.TCOD_random_new_from_seed(
 			C.TCOD_random_algo_t(RNG_MT),
-			C.uint32_t(seed)))
+			C.uint32_t(seed)), RNG_MT)
 }
 
 func (random *Random) Save() *Random {
-	result := newRandom(C.TCOD_random_save(random.Data))
+	result := newRandom(C.TCOD_random_save(random.Data), random.algo)
+	result.distribution = random.distribution
 	return result
 }
 
 func (random *Random) Restore(backup *Random) {
 	C.TCOD_random_restore(random.Data, backup.Data)
+	random.algo, random.distribution = backup.algo, backup.distribution
 }
 
 func (random *Random) SetDistribution(distribution Distribution) {
 	C.TCOD_random_set_distribution(random.Data, C.TCOD_distribution_t(distribution))
+	random.distribution = distribution
 }
 
 func (random *Random) GetInt(min, max int) int {
@@ -2243,6 +2454,107 @@ func (noise *Noise) GetTurbulence(f FloatArray, octaves float32) float32 {
 	return float32(C.TCOD_noise_get_turbulence(noise.Data, (*C.float)(unsafe.Pointer(&f[0])), C.float(octaves)))
 }
 
+//
+// Batch sampling
+//
+// The Sample methods fill a slice with the noise at many points in a single cgo
+// call.
+//
+
+// vectorArgs returns the sample count and the coordinate arrays for libtcod, with
+// nil for unused dimensions.  libtcod reads a coordinate array for each of the
+// noise's dimensions, so the count is 0 if coords has too few.
+func (noise *Noise) vectorArgs(out []float32, coords [][]float32) (n C.int, x [4]*C.float) {
+	if len(coords) < int(noise.Data.ndim) {
+		return 0, x
+	}
+	count := len(out)
+	for _, c := range coords {
+		count = min(count, len(c))
+	}
+	if count == 0 {
+		return 0, x
+	}
+	for i := 0; i < len(coords) && i < len(x); i++ {
+		x[i] = (*C.float)(unsafe.Pointer(&coords[i][0]))
+	}
+	return C.int(count), x
+}
+
+// Sample fills out with the noise at many points in one call.  coords holds one
+// slice per dimension of the noise, so the i'th point is coords[0][i],
+// coords[1][i], and so on, and its noise goes in out[i].  Dimensions beyond the
+// fourth are ignored.  Only as many points as the shortest of out and coords
+// are sampled, and Sample returns that number; if coords has fewer slices than
+// the noise has dimensions, nothing is sampled.
+func (noise *Noise) Sample(noiseType NoiseType, out []float32, coords ...[]float32) int {
+	n, x := noise.vectorArgs(out, coords)
+	if n > 0 {
+		C.TCOD_noise_get_vectorized(noise.Data, C.TCOD_noise_type_t(noiseType), n,
+			x[0], x[1], x[2], x[3], (*C.float)(unsafe.Pointer(&out[0])))
+	}
+	return int(n)
+}
+
+// SampleFbm is like Sample, but fills out with fractional Brownian motion
+// noise of the given octaves.  It returns the number of points sampled.
+func (noise *Noise) SampleFbm(noiseType NoiseType, octaves float32, out []float32, coords ...[]float32) int {
+	n, x := noise.vectorArgs(out, coords)
+	if n > 0 {
+		C.TCOD_noise_get_fbm_vectorized(noise.Data, C.TCOD_noise_type_t(noiseType), C.float(octaves), n,
+			x[0], x[1], x[2], x[3], (*C.float)(unsafe.Pointer(&out[0])))
+	}
+	return int(n)
+}
+
+// SampleTurbulence is like Sample, but fills out with turbulence noise of the
+// given octaves.  It returns the number of points sampled.
+func (noise *Noise) SampleTurbulence(noiseType NoiseType, octaves float32, out []float32, coords ...[]float32) int {
+	n, x := noise.vectorArgs(out, coords)
+	if n > 0 {
+		C.TCOD_noise_get_turbulence_vectorized(noise.Data, C.TCOD_noise_type_t(noiseType), C.float(octaves), n,
+			x[0], x[1], x[2], x[3], (*C.float)(unsafe.Pointer(&out[0])))
+	}
+	return int(n)
+}
+
+// SampleGrid fills out with a 2D noise at every combination of xs and ys, in
+// row-major order: out[j*len(xs)+i] is the noise at xs[i], ys[j].  If octaves is
+// above 0, fbm noise is sampled.  out must hold len(xs)*len(ys) values.
+func (noise *Noise) SampleGrid(noiseType NoiseType, octaves float32, xs, ys, out []float32) {
+	n := len(xs) * len(ys)
+	if n == 0 {
+		return
+	}
+	gx, gy := make([]float32, n), make([]float32, n)
+	for j, y := range ys {
+		copy(gx[j*len(xs):], xs)
+		for i := range xs {
+			gy[j*len(xs)+i] = y
+		}
+	}
+	if octaves > 0 {
+		noise.SampleFbm(noiseType, octaves, out[:n], gx, gy)
+	} else {
+		noise.Sample(noiseType, out[:n], gx, gy)
+	}
+}
+
+// FillHeightMap replaces the heightmap's values with a 2D noise.  The cell at x, y
+// takes the noise at ((x+offsetX)*zoom, (y+offsetY)*zoom); if octaves is above 0,
+// fbm noise is used.
+func (noise *Noise) FillHeightMap(heightMap *HeightMap, noiseType NoiseType, zoom, offsetX, offsetY, octaves float32) {
+	w, h := heightMap.GetWidth(), heightMap.GetHeight()
+	xs, ys := make([]float32, w), make([]float32, h)
+	for x := range xs {
+		xs[x] = (float32(x) + offsetX) * zoom
+	}
+	for y := range ys {
+		ys[y] = (float32(y) + offsetY) * zoom
+	}
+	noise.SampleGrid(noiseType, octaves, xs, ys, heightMap.Values())
+}
+
 //
 // Zip
 //
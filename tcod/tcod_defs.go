@@ -14,6 +14,7 @@ const (
 	BkgndColorBurn  = C.TCOD_BKGND_COLOR_BURN
 	BkgndColorDodge = C.TCOD_BKGND_COLOR_DODGE
 	BkgndDarken     = C.TCOD_BKGND_DARKEN
+	BkgndDefault    = C.TCOD_BKGND_DEFAULT
 	BkgndLighten    = C.TCOD_BKGND_LIGHTEN
 	BkgndMultiply   = C.TCOD_BKGND_MULTIPLY
 	BkgndNone       = C.TCOD_BKGND_NONE
@@ -179,6 +180,18 @@ const (
 	NBRenderers = C.TCOD_NB_RENDERERS
 )
 
+/* SDL window flags, for ContextOptions.SDLWindowFlags */
+const (
+	WindowFullscreen        = 0x00000001
+	WindowHidden            = 0x00000008
+	WindowBorderless        = 0x00000010
+	WindowResizable         = 0x00000020
+	WindowMinimized         = 0x00000040
+	WindowMaximized         = 0x00000080
+	WindowFullscreenDesktop = WindowFullscreen | 0x00001000
+	WindowAllowHighDPI      = 0x00002000
+)
+
 /* alignment enum */
 const (
 	Left   = C.TCOD_LEFT
@@ -196,6 +209,7 @@ const (
 )
 
 const (
+	EventNone          = 0
 	EventKeyPress      = 1
 	EventKeyRelease    = 2
 	EventKey           = 3
@@ -0,0 +1,119 @@
+package tcod
+
+/*
+ #include "include/libtcod.h"
+*/
+import "C"
+
+import (
+	"unsafe"
+)
+
+//
+// Console tiles
+//
+
+// Tile is a single console cell.  It has the same memory layout as libtcod's
+// TCOD_ConsoleTile, so whole regions can be copied to and from a console without
+// a cgo call per cell.
+type Tile struct {
+	Ch      rune
+	Fg      Color
+	FgAlpha uint8
+	Bg      Color
+	BgAlpha uint8
+}
+
+// Tile must stay the same size as TCOD_ConsoleTile.
+var _ [unsafe.Sizeof(Tile{}) - C.sizeof_TCOD_ConsoleTile]byte
+var _ [C.sizeof_TCOD_ConsoleTile - unsafe.Sizeof(Tile{})]byte
+
+// NewTile returns an opaque tile with the given glyph and colors.
+func NewTile(ch rune, fg, bg Color) Tile {
+	return Tile{Ch: ch, Fg: fg, FgAlpha: 255, Bg: bg, BgAlpha: 255}
+}
+
+// Tiles returns the console's tiles in row-major order, as a slice backed by
+// libtcod's own buffer.  Changes to the slice are changes to the console.  The
+// slice must not be used after the console is deleted, so keep a reference to the
+// console for as long as the slice is in use.
+//
+// The root console isn't backed by a Go Console, so Tiles returns nil for it.
+func (console *Console) Tiles() []Tile {
+	if console.Data == nil {
+		return nil
+	}
+	n := int(console.Data.w * console.Data.h)
+	return (*[1 << 28]Tile)(unsafe.Pointer(console.Data.tiles))[:n:n]
+}
+
+// GetTiles copies the w x h region at x, y into a new slice, in row-major order.
+// Cells outside the console are returned as zero tiles.
+func (console *Console) GetTiles(x, y, w, h int) []Tile {
+	return getTiles(console.Tiles(), console.GetWidth(), console.GetHeight(), x, y, w, h)
+}
+
+// SetTiles copies tiles, in row-major order, into the region w cells wide at x, y.
+// Tiles falling outside the console are skipped.
+func (console *Console) SetTiles(x, y, w int, tiles []Tile) {
+	setTiles(console.Tiles(), console.GetWidth(), console.GetHeight(), x, y, w, tiles)
+}
+
+// Tiles returns the headless console's tiles in row-major order.  Changes to the
+// slice are changes to the console.
+func (console *HeadlessConsole) Tiles() []Tile {
+	return headlessTiles(console.console.Tiles())
+}
+
+// GetTiles copies the w x h region at x, y into a new slice, in row-major order.
+// Cells outside the console are returned as zero tiles.
+func (console *HeadlessConsole) GetTiles(x, y, w, h int) []Tile {
+	return getTiles(console.Tiles(), console.GetWidth(), console.GetHeight(), x, y, w, h)
+}
+
+// SetTiles copies tiles, in row-major order, into the region w cells wide at x, y.
+// Tiles falling outside the console are skipped.
+func (console *HeadlessConsole) SetTiles(x, y, w int, tiles []Tile) {
+	setTiles(console.Tiles(), console.GetWidth(), console.GetHeight(), x, y, w, tiles)
+}
+
+func getTiles(src []Tile, srcW, srcH, x, y, w, h int) []Tile {
+	result := make([]Tile, w*h)
+	if src == nil {
+		return result
+	}
+	for row := 0; row < h; row++ {
+		x0, x1, ok := clipRow(srcW, srcH, x, y+row, w)
+		if !ok {
+			continue
+		}
+		copy(result[row*w+x0-x:], src[(y+row)*srcW+x0:(y+row)*srcW+x1])
+	}
+	return result
+}
+
+func setTiles(dst []Tile, dstW, dstH, x, y, w int, tiles []Tile) {
+	if dst == nil || w <= 0 {
+		return
+	}
+	for row := 0; row*w < len(tiles); row++ {
+		x0, x1, ok := clipRow(dstW, dstH, x, y+row, w)
+		if !ok {
+			continue
+		}
+		start, end := row*w+x0-x, min(row*w+x1-x, len(tiles))
+		if start < end {
+			copy(dst[(y+row)*dstW+x0:], tiles[start:end])
+		}
+	}
+}
+
+// clipRow clips a row of w cells at x, y to a width x height console, returning
+// the first and last+1 columns inside it.
+func clipRow(width, height, x, y, w int) (x0, x1 int, ok bool) {
+	if y < 0 || y >= height {
+		return 0, 0, false
+	}
+	x0, x1 = max(x, 0), min(x+w, width)
+	return x0, x1, x0 < x1
+}
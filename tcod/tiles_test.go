@@ -0,0 +1,47 @@
+package tcod
+
+import (
+	"testing"
+)
+
+const benchConsoleW, benchConsoleH = 80, 50
+
+// BenchmarkConsoleTiles recolours every cell through the Tiles slice.
+func BenchmarkConsoleTiles(b *testing.B) {
+	console := NewConsole(benchConsoleW, benchConsoleH)
+	for i := 0; i < b.N; i++ {
+		tiles := console.Tiles()
+		for j := range tiles {
+			tiles[j] = NewTile('#', tiles[j].Bg, tiles[j].Fg)
+		}
+	}
+}
+
+// BenchmarkConsoleGetSetTiles recolours every cell through copies made by
+// GetTiles and SetTiles.
+func BenchmarkConsoleGetSetTiles(b *testing.B) {
+	console := NewConsole(benchConsoleW, benchConsoleH)
+	for i := 0; i < b.N; i++ {
+		tiles := console.GetTiles(0, 0, benchConsoleW, benchConsoleH)
+		for j := range tiles {
+			tiles[j] = NewTile('#', tiles[j].Bg, tiles[j].Fg)
+		}
+		console.SetTiles(0, 0, benchConsoleW, tiles)
+	}
+}
+
+// BenchmarkConsolePerCell recolours every cell with a cgo call per value, for
+// comparison.
+func BenchmarkConsolePerCell(b *testing.B) {
+	console := NewConsole(benchConsoleW, benchConsoleH)
+	for i := 0; i < b.N; i++ {
+		for y := 0; y < benchConsoleH; y++ {
+			for x := 0; x < benchConsoleW; x++ {
+				fore, back := console.GetCharForeground(x, y), console.GetCharBackground(x, y)
+				console.SetChar(x, y, '#')
+				console.SetCharForeground(x, y, back)
+				console.SetCharBackground(x, y, fore, BkgndSet)
+			}
+		}
+	}
+}
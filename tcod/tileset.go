@@ -0,0 +1,188 @@
+package tcod
+
+/*
+ #include <stdlib.h>
+ #include "include/libtcod.h"
+*/
+import "C"
+
+import (
+	"errors"
+	"fmt"
+	"runtime"
+	"unsafe"
+)
+
+//
+// Tileset
+//
+
+// CharmapCP437 maps the tiles of a Code Page 437 tilesheet to Unicode codepoints.
+var CharmapCP437 = toCharmap(C.TCOD_CHARMAP_CP437[:])
+
+// CharmapTCOD maps the tiles of the deprecated TCOD tilesheet layout to Unicode
+// codepoints.
+var CharmapTCOD = toCharmap(C.TCOD_CHARMAP_TCOD[:])
+
+func toCharmap(cmap []C.int) []int {
+	result := make([]int, len(cmap))
+	for i, c := range cmap {
+		result[i] = int(c)
+	}
+	return result
+}
+
+// Tileset holds the glyphs used to render a console, keyed by Unicode codepoint.
+// A tileset is reference counted by libtcod, so it may be shared by several
+// contexts.
+type Tileset struct {
+	Data *C.TCOD_Tileset
+}
+
+func deleteTileset(ts *Tileset) {
+	C.TCOD_tileset_delete(ts.Data)
+}
+
+func newTileset(data *C.TCOD_Tileset) (*Tileset, error) {
+	if data == nil {
+		return nil, errors.New(C.GoString(C.TCOD_get_error()))
+	}
+	result := &Tileset{data}
+	runtime.SetFinalizer(result, deleteTileset)
+	return result, nil
+}
+
+// LoadTilesheet loads a PNG tilesheet laid out in the given number of columns and
+// rows.  Tiles are assigned codepoints in row-major order from charmap, such as
+// CharmapCP437.
+func LoadTilesheet(filename string, columns, rows int, charmap []int) (*Tileset, error) {
+	cfilename := C.CString(filename)
+	defer C.free(unsafe.Pointer(cfilename))
+	return newTileset(C.TCOD_tileset_load(cfilename, C.int(columns), C.int(rows), C.int(len(charmap)), fromCharmap(charmap)))
+}
+
+// LoadTilesheetMemory is the same as LoadTilesheet, but decodes the PNG tilesheet
+// from data.
+func LoadTilesheetMemory(data []byte, columns, rows int, charmap []int) (*Tileset, error) {
+	if len(data) == 0 {
+		return nil, errors.New("tcod: no tilesheet data")
+	}
+	return newTileset(C.TCOD_tileset_load_mem(C.size_t(len(data)), (*C.uchar)(unsafe.Pointer(&data[0])),
+		C.int(columns), C.int(rows), C.int(len(charmap)), fromCharmap(charmap)))
+}
+
+// LoadTilesheetRaw is the same as LoadTilesheet, but takes the tilesheet as a
+// width x height image of RGBA pixels, four bytes per pixel in row-major order.
+func LoadTilesheetRaw(width, height int, pixels []byte, columns, rows int, charmap []int) (*Tileset, error) {
+	if width <= 0 || height <= 0 || len(pixels) != width*height*4 {
+		return nil, fmt.Errorf("tcod: %d bytes of pixels doesn't fit a %dx%d RGBA image", len(pixels), width, height)
+	}
+	return newTileset(C.TCOD_tileset_load_raw(C.int(width), C.int(height), fromPixels(pixels),
+		C.int(columns), C.int(rows), C.int(len(charmap)), fromCharmap(charmap)))
+}
+
+// LoadBDF loads a BDF bitmap font.
+func LoadBDF(filename string) (*Tileset, error) {
+	cfilename := C.CString(filename)
+	defer C.free(unsafe.Pointer(cfilename))
+	return newTileset(C.TCOD_load_bdf(cfilename))
+}
+
+// LoadBDFMemory loads a BDF bitmap font from data.
+func LoadBDFMemory(data []byte) (*Tileset, error) {
+	if len(data) == 0 {
+		return nil, errors.New("tcod: no BDF data")
+	}
+	return newTileset(C.TCOD_load_bdf_memory(C.int(len(data)), (*C.uchar)(unsafe.Pointer(&data[0]))))
+}
+
+// LoadTrueType renders a TrueType font into tiles of tileWidth x tileHeight
+// pixels.
+func LoadTrueType(filename string, tileWidth, tileHeight int) (*Tileset, error) {
+	cfilename := C.CString(filename)
+	defer C.free(unsafe.Pointer(cfilename))
+	return newTileset(C.TCOD_load_truetype_font_(cfilename, C.int(tileWidth), C.int(tileHeight)))
+}
+
+// NewTileset creates an empty tileset with tiles of tileWidth x tileHeight pixels.
+func NewTileset(tileWidth, tileHeight int) (*Tileset, error) {
+	return newTileset(C.TCOD_tileset_new(C.int(tileWidth), C.int(tileHeight)))
+}
+
+// fromPixels converts RGBA bytes into a pixel array for libtcod.
+func fromPixels(pixels []byte) *C.struct_TCOD_ColorRGBA {
+	return (*C.struct_TCOD_ColorRGBA)(unsafe.Pointer(&pixels[0]))
+}
+
+// fromCharmap converts a Go charmap into an int array for libtcod; the
+// result is nil for an empty charmap.
+func fromCharmap(charmap []int) *C.int {
+	if len(charmap) == 0 {
+		return nil
+	}
+	result := make([]C.int, len(charmap))
+	for i, c := range charmap {
+		result[i] = C.int(c)
+	}
+	return &result[0]
+}
+
+func (ts *Tileset) GetTileWidth() int {
+	return int(ts.Data.tile_width)
+}
+
+func (ts *Tileset) GetTileHeight() int {
+	return int(ts.Data.tile_height)
+}
+
+// GetTilePixels returns a copy of the tile assigned to codepoint, as RGBA pixels,
+// four bytes per pixel in row-major order.
+func (ts *Tileset) GetTilePixels(codepoint rune) ([]byte, error) {
+	pixels := make([]byte, ts.GetTileWidth()*ts.GetTileHeight()*4)
+	if err := toError(C.TCOD_tileset_get_tile_(ts.Data, C.int(codepoint), fromPixels(pixels))); err != nil {
+		return nil, err
+	}
+	return pixels, nil
+}
+
+// SetTilePixels sets the tile for codepoint from RGBA pixels, four bytes per pixel
+// in row-major order.  The pixels must cover exactly one tile.  Contexts using the
+// tileset pick up the change the next time they present.
+func (ts *Tileset) SetTilePixels(codepoint rune, pixels []byte) error {
+	if len(pixels) != ts.GetTileWidth()*ts.GetTileHeight()*4 {
+		return fmt.Errorf("tcod: %d bytes of pixels doesn't fit a %dx%d tile", len(pixels), ts.GetTileWidth(), ts.GetTileHeight())
+	}
+	return toError(C.TCOD_tileset_set_tile_(ts.Data, C.int(codepoint), fromPixels(pixels)))
+}
+
+// SetTileFromImage sets the tile for codepoint from an image the size of one tile.
+// Pixels matching the image's key color are made transparent.
+func (ts *Tileset) SetTileFromImage(codepoint rune, image *Image) error {
+	var w, h int
+	image.GetSize(&w, &h)
+	if w != ts.GetTileWidth() || h != ts.GetTileHeight() {
+		return fmt.Errorf("tcod: a %dx%d image doesn't fit a %dx%d tile", w, h, ts.GetTileWidth(), ts.GetTileHeight())
+	}
+
+	pixels := make([]byte, 0, w*h*4)
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			c := image.GetPixel(x, y)
+			alpha := byte(255)
+			if toBool(C.TCOD_image_is_pixel_transparent(image.Data, C.int(x), C.int(y))) {
+				alpha = 0
+			}
+			pixels = append(pixels, c.R, c.G, c.B, alpha)
+		}
+	}
+	return ts.SetTilePixels(codepoint, pixels)
+}
+
+// AssignTile makes codepoint share the tile already loaded as tileID, which is
+// the tile's position in the tilesheet.
+func (ts *Tileset) AssignTile(tileID int, codepoint rune) error {
+	if C.TCOD_tileset_assign_tile(ts.Data, C.int(tileID), C.int(codepoint)) < 0 {
+		return errors.New(C.GoString(C.TCOD_get_error()))
+	}
+	return nil
+}